@@ -9,6 +9,7 @@ require (
 	github.com/snipem/go-gt7-telemetry v0.0.0-20240430151727-42de380f2d06
 	github.com/snipem/gt7tools v0.0.0-20240415065935-7c23d8b916df
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/image v0.18.0
 )
 
 require (
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/gorilla/websocket"
@@ -8,15 +12,18 @@ import (
 	"github.com/snipem/gt7fuel/lib"
 	"github.com/snipem/gt7fuel/lib/experimental"
 	"github.com/snipem/gt7tools/lib/dump"
+	"io/fs"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -26,8 +33,49 @@ var gt7c *gt7.GT7Communication
 var gt7stats *lib.Stats
 var raceTimeInMinutes int
 
+// sessionDir is the directory stored sessions are loaded from
+var sessionDir string
+
+// includeIncidentLaps includes laps with incidents in averages
+var includeIncidentLaps bool
+
 var WaitTime = 100 * time.Millisecond
 
+//go:embed web
+var embeddedWeb embed.FS
+
+// webDir is the directory the web assets are served from during development, empty for the embedded assets
+var webDir string
+
+// getWebAssets returns the assets of the dashboard, embedded into the binary or from webDir
+func getWebAssets() fs.FS {
+	if webDir != "" {
+		return os.DirFS(webDir)
+	}
+	assets, err := fs.Sub(embeddedWeb, "web")
+	if err != nil {
+		log.Fatalf("Error loading embedded web assets: %v", err)
+	}
+	return assets
+}
+
+// serveWebAsset serves an asset of the dashboard. Browsers revalidate the assets with the
+// ETag, assets from webDir are not cached at all to see changes immediately
+func serveWebAsset(w http.ResponseWriter, r *http.Request, name string) {
+	content, err := fs.ReadFile(getWebAssets(), name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if webDir != "" {
+		w.Header().Set("Cache-Control", "no-store")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(content)))
+	}
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+}
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
@@ -170,17 +218,624 @@ func homePage(w http.ResponseWriter, r *http.Request) {
 			raceTimeInMinutes = convertedRacetimeInMinutes
 		}
 	}
-	http.ServeFile(w, r, "./index.html")
+
+	averagingQuery := m.Get("avg")
+	if averagingQuery != "" {
+		err := gt7stats.SetAveragingMethod(averagingQuery)
+		if err != nil {
+			log.Printf("Cannot set averaging method: %v\n", err)
+		}
+	}
+
+	averagingWindowQuery := m.Get("avg-window")
+	if averagingWindowQuery != "" {
+		averagingWindow, err := strconv.Atoi(averagingWindowQuery)
+		if err != nil {
+			log.Printf("Cannot convert %s\n", averagingWindowQuery)
+		} else {
+			gt7stats.Averaging.Window = averagingWindow
+		}
+	}
+
+	// every path that is not an asset shows the dashboard, e.g. /static?min=60
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if info, err := fs.Stat(getWebAssets(), name); err != nil || info.IsDir() {
+		name = "index.html"
+	}
+	serveWebAsset(w, r, name)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("Error writing JSON: %s\n", err)
+	}
+}
+
+func handleBraking(w http.ResponseWriter, r *http.Request) {
+	feedback, err := gt7stats.GetBrakingFeedback()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, feedback)
+}
+
+func handleTraction(w http.ResponseWriter, r *http.Request) {
+	traction, err := gt7stats.GetTractionAnalysis()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, traction)
+}
+
+func handleShifts(w http.ResponseWriter, r *http.Request) {
+	comparison, err := gt7stats.GetShiftComparison()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, comparison)
+}
+
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, gt7stats.GetHealthReport())
+}
+
+// getSessionStats returns the stats of the stored session or of the live session if name is empty
+func getSessionStats(name string) (*lib.Stats, error) {
+	if name == "" {
+		return gt7stats, nil
+	}
+	path, err := lib.GetSessionPath(sessionDir, name)
+	if err != nil {
+		return nil, err
+	}
+	return lib.LoadSession(path)
+}
+
+// handleSuspension returns the suspension report of the live session and of every stored session given by the session parameter
+func handleSuspension(w http.ResponseWriter, r *http.Request) {
+	sessions := r.URL.Query()["session"]
+	if len(sessions) == 0 {
+		sessions = []string{""}
+	}
+
+	reports := map[string][]lib.LapSuspension{}
+	for _, session := range sessions {
+		stats, err := getSessionStats(session)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		report, err := stats.GetSuspensionReport()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error getting suspension report of session %q: %v", session, err), http.StatusNotFound)
+			return
+		}
+		if session == "" {
+			session = "live"
+		}
+		reports[session] = report
+	}
+	writeJSON(w, reports)
+}
+
+// handleRideHeight plots the ride height versus speed of a lap, by default of the last lap
+func handleRideHeight(w http.ResponseWriter, r *http.Request) {
+	stats, err := getSessionStats(r.URL.Query().Get("session"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if len(stats.Laps) == 0 {
+		http.Error(w, "no lap driven yet", http.StatusNotFound)
+		return
+	}
+
+	lap := stats.Laps[len(stats.Laps)-1]
+	if lapParam := r.URL.Query().Get("lap"); lapParam != "" {
+		number, err := strconv.Atoi(lapParam)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid lap %q: %v", lapParam, err), http.StatusBadRequest)
+			return
+		}
+		lap, err = lib.GetLap(stats.Laps, int16(number))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+
+	writeGraphic(w, r, lib.DrawRideHeightBySpeedSVG(lib.GetRideHeightBySpeed(lap)))
+}
+
+// defaultPNGWidth is the width of rasterized graphics in pixels if no width is requested
+const defaultPNGWidth = 800
+
+// writeGraphic writes the SVG, or a PNG of the requested width if the format parameter is png
+func writeGraphic(w http.ResponseWriter, r *http.Request, svg string) {
+	query := r.URL.Query()
+	if query.Get("format") != "png" {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		fmt.Fprint(w, svg)
+		return
+	}
+
+	width := defaultPNGWidth
+	if widthParam := query.Get("width"); widthParam != "" {
+		var err error
+		width, err = strconv.Atoi(widthParam)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid width %q: %v", widthParam, err), http.StatusBadRequest)
+			return
+		}
+	}
+	buf := new(bytes.Buffer)
+	err := lib.WriteSVGAsPNG(buf, svg, width)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(buf.Bytes())
+}
+
+// handleMap returns the live track map
+func handleMap(w http.ResponseWriter, r *http.Request) {
+	writeGraphic(w, r, gt7stats.DrawTrackMapSVG())
+}
+
+// getRequestedLap returns the lap with the number, or the default lap if number is empty
+func getRequestedLap(laps []lib.Lap, number string, defaultLap func() (lib.Lap, error)) (lib.Lap, error) {
+	if number == "" {
+		return defaultLap()
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return lib.Lap{}, fmt.Errorf("invalid lap %q: %v", number, err)
+	}
+	return lib.GetLap(laps, int16(n))
+}
+
+// handleCompare overlays the racing line of a lap with a reference lap, by default the last lap
+// is compared with the best lap. Both laps can be taken from stored sessions
+func handleCompare(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	stats, err := getSessionStats(query.Get("session"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	referenceStats := stats
+	if query.Get("reference-session") != "" {
+		referenceStats, err = getSessionStats(query.Get("reference-session"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+
+	lap, err := getRequestedLap(stats.Laps, query.Get("lap"), stats.GetLastLap)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	reference, err := getRequestedLap(referenceStats.Laps, query.Get("reference"), referenceStats.GetBestLap)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	comparison := lib.LapComparison{Lap: lap, Reference: reference, Color: lib.OverlayColorDelta}
+	if color := query.Get("color"); color != "" {
+		comparison.Color = color
+	}
+	if corner := query.Get("corner"); corner != "" {
+		comparison.Corner, err = strconv.Atoi(corner)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid corner %q: %v", corner, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	svg, err := lib.DrawLapComparisonSVG(comparison)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeGraphic(w, r, svg)
+}
+
+// handleHeatmap draws the track map colored by the channel, by default the last lap by speed
+func handleHeatmap(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	stats, err := getSessionStats(query.Get("session"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	lap, err := getRequestedLap(stats.Laps, query.Get("lap"), stats.GetLastLap)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	channel := query.Get("channel")
+	if channel == "" {
+		channel = lib.HeatmapSpeed
+	}
+	svg, err := lib.DrawLapHeatmapSVG(lap, channel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeGraphic(w, r, svg)
+}
+
+// handleTrace charts a channel of a lap over the distance, by default the speed of the last lap.
+// The reference lap is only drawn if it is requested
+func handleTrace(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	stats, err := getSessionStats(query.Get("session"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	lap, err := getRequestedLap(stats.Laps, query.Get("lap"), stats.GetLastLap)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	reference, err := getRequestedLap(stats.Laps, query.Get("reference"), func() (lib.Lap, error) {
+		return lib.Lap{}, nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	channel := query.Get("channel")
+	if channel == "" {
+		channel = lib.TraceSpeed
+	}
+	svg, err := lib.DrawTraceSVG(lap, reference, channel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeGraphic(w, r, svg)
+}
+
+// handleReport returns the HTML report of the live session or of a stored session
+func handleReport(w http.ResponseWriter, r *http.Request) {
+	session := r.URL.Query().Get("session")
+	stats, err := getSessionStats(session)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	title := session
+	if title == "" {
+		title = "Live session"
+	}
+	if stats.Track != "" {
+		title = stats.Track + " - " + title
+	}
+
+	buf := new(bytes.Buffer)
+	err = stats.WriteHTMLReport(buf, title)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// setExportHeaders makes the browser download the export
+func setExportHeaders(w http.ResponseWriter, format string, name string) {
+	contentType := "text/csv"
+	if format == lib.ExportJSONLines {
+		contentType = "application/jsonl"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+}
+
+// getSessionName returns the name of the stored session without the extensions of the dump file
+func getSessionName(session string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(filepath.Base(session), ".gz"), ".gob")
+}
+
+// getMoTeCInfo names the MoTeC event after the session, path is the dump file of a stored session
+// and empty for the live session
+func getMoTeCInfo(name string, path string, data []gt7.GTData) lib.MoTeCInfo {
+	info := lib.MoTeCInfo{
+		Event:   name,
+		Session: "Race",
+		Time:    time.Now(),
+	}
+	if len(data) > 0 {
+		info.Vehicle = fmt.Sprintf("Car %d", data[len(data)-1].CarID)
+	}
+	if path == "" {
+		return info
+	}
+	if fileInfo, err := os.Stat(path); err == nil {
+		info.Time = fileInfo.ModTime()
+	}
+	return info
+}
+
+// readMoTeCData returns every package of the dump file of a stored session, the laps of a loaded
+// session only have every few packages like live
+func readMoTeCData(path string) ([]gt7.GTData, error) {
+	data, err := dump.ReadGT7Data(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading session %s: %v", path, err)
+	}
+	return data, nil
+}
+
+// handleExportMoTeC downloads the live or a stored session as MoTeC .ld log
+func handleExportMoTeC(w http.ResponseWriter, r *http.Request) {
+	session := r.URL.Query().Get("session")
+	name, path, data := "live", "", gt7stats.GetSessionData()
+	if session != "" {
+		var err error
+		path, err = lib.GetSessionPath(sessionDir, session)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		data, err = readMoTeCData(path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		name = getSessionName(session)
+	}
+
+	buf := new(bytes.Buffer)
+	err := lib.WriteMoTeC(buf, data, getMoTeCInfo(name, path, data))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".ld"))
+	w.Write(buf.Bytes())
+}
+
+// handleExportLaps downloads the lap summaries of the live or a stored session
+func handleExportLaps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	stats, err := getSessionStats(query.Get("session"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = lib.ExportCSV
+	}
+
+	buf := new(bytes.Buffer)
+	err = lib.ExportLaps(buf, stats.Laps, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	setExportHeaders(w, format, "laps")
+	w.Write(buf.Bytes())
+}
+
+// handleExportTelemetry downloads the telemetry of a lap, by default of the last lap
+func handleExportTelemetry(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	stats, err := getSessionStats(query.Get("session"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	lap, err := getRequestedLap(stats.Laps, query.Get("lap"), stats.GetLastLap)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	channels, err := lib.ParseChannels(query.Get("channels"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	every := 1
+	if everyParam := query.Get("every"); everyParam != "" {
+		every, err = strconv.Atoi(everyParam)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid every %q: %v", everyParam, err), http.StatusBadRequest)
+			return
+		}
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = lib.ExportCSV
+	}
+
+	buf := new(bytes.Buffer)
+	err = lib.ExportTelemetry(buf, lap, channels, every, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	setExportHeaders(w, format, fmt.Sprintf("lap-%d", lap.Number))
+	w.Write(buf.Bytes())
+}
+
+func handleSpeedTraps(w http.ResponseWriter, r *http.Request) {
+	report, err := gt7stats.GetSpeedTrapReport()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, report)
+}
+
+func handleConsistency(w http.ResponseWriter, r *http.Request) {
+	report, err := gt7stats.GetConsistencyReport()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, report)
 }
 
 func setupRoutes() {
 	http.HandleFunc("/", homePage)
+	http.HandleFunc("/api/braking", handleBraking)
+	http.HandleFunc("/api/traction", handleTraction)
+	http.HandleFunc("/api/shifts", handleShifts)
+	http.HandleFunc("/api/health", handleHealth)
+	http.HandleFunc("/api/suspension", handleSuspension)
+	http.HandleFunc("/api/speed-traps", handleSpeedTraps)
+	http.HandleFunc("/api/consistency", handleConsistency)
+	http.HandleFunc("/api/report", handleReport)
+	http.HandleFunc("/api/export/laps", handleExportLaps)
+	http.HandleFunc("/api/export/telemetry", handleExportTelemetry)
+	http.HandleFunc("/api/export/motec", handleExportMoTeC)
+	http.HandleFunc("/ride-height.svg", handleRideHeight)
+	http.HandleFunc("/compare.svg", handleCompare)
+	http.HandleFunc("/heatmap.svg", handleHeatmap)
+	http.HandleFunc("/trace.svg", handleTrace)
+	http.HandleFunc("/map.svg", handleMap)
 	http.HandleFunc("/realtimews", handleRealtimeWebSocketConnection)
 	http.HandleFunc("/heavyws", handleHeavyWebSocketConnection)
 }
 
+// runReport writes the HTML report of a stored session, used as gt7fuel report <session>
+func runReport(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	output := flags.String("output", "", "HTML file to write the report to, by default the session file name with .html")
+	includeIncidentLapsFlag := flags.Bool("include-incident-laps", false, "Include laps with incidents in averages and the lap time deviation")
+	lapColumnsFlag := flags.String("lap-columns", "", fmt.Sprintf("Columns of the lap table separated by commas, by default all of %v", lib.LapTableColumns))
+	languageFlag := flags.String("language", lib.LanguageEnglish, fmt.Sprintf("Language of the report, one of %v", lib.Languages))
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s report [flags] <session dump file>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected one session, got %d", flags.NArg())
+	}
+
+	sessionPath := flags.Arg(0)
+	stats, err := lib.LoadSession(sessionPath)
+	if err != nil {
+		return err
+	}
+	stats.IncludeIncidentLaps = *includeIncidentLapsFlag
+	stats.Settings.LapTableColumns, err = lib.ParseLapTableColumns(*lapColumnsFlag)
+	if err != nil {
+		return err
+	}
+	stats.Settings.Language, err = lib.ParseLanguage(*languageFlag)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		// dump files are named like race.gob.gz
+		base := strings.TrimSuffix(sessionPath, ".gz")
+		*output = strings.TrimSuffix(base, filepath.Ext(base)) + ".html"
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("error creating report: %v", err)
+	}
+	defer f.Close()
+
+	err = stats.WriteHTMLReport(f, filepath.Base(sessionPath))
+	if err != nil {
+		return err
+	}
+	log.Printf("Wrote report to %s\n", *output)
+	return nil
+}
+
+// runExport writes the lap summaries or the telemetry of a lap of a stored session, used as gt7fuel export <session>
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	lapFlag := flags.Int("lap", 0, "Lap to export the telemetry of, by default the lap summaries are exported")
+	format := flags.String("format", lib.ExportCSV, fmt.Sprintf("Export format, one of %v or %s for a MoTeC .ld log of the whole session", lib.ExportFormats, lib.ExportMoTeC))
+	channelsFlag := flags.String("channels", "", fmt.Sprintf("Telemetry channels separated by commas, by default all of %v", lib.TelemetryChannels))
+	every := flags.Int("every", 1, "Export every nth package of the telemetry")
+	output := flags.String("output", "", "File to export to, by default stdout")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s export [flags] <session dump file>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected one session, got %d", flags.NArg())
+	}
+
+	w := os.Stdout
+	if *output != "" {
+		var err error
+		w, err = os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("error creating export: %v", err)
+		}
+		defer w.Close()
+	}
+
+	if *format == lib.ExportMoTeC {
+		data, err := readMoTeCData(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		return lib.WriteMoTeC(w, data, getMoTeCInfo(getSessionName(flags.Arg(0)), flags.Arg(0), data))
+	}
+
+	stats, err := lib.LoadSession(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *lapFlag == 0 {
+		return lib.ExportLaps(w, stats.Laps, *format)
+	}
+	lap, err := lib.GetLap(stats.Laps, int16(*lapFlag))
+	if err != nil {
+		return err
+	}
+	channels, err := lib.ParseChannels(*channelsFlag)
+	if err != nil {
+		return err
+	}
+	return lib.ExportTelemetry(w, lap, channels, *every, *format)
+}
+
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		err := runReport(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Error writing report: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		err := runExport(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Error exporting: %v", err)
+		}
+		return
+	}
+
 	//FIXME delete the oldest pictures in the tire parsing dir
 	parseTwitch := flag.Bool("parse-twitch", true, "Set to true to enable parsing Twitch")
 	raceTime := flag.Int("race-time", 60, "Race time in minutes")
@@ -188,20 +843,66 @@ func main() {
 
 	dumpFile := flag.String("dump-file", "", "Dump file for loading dumped data instead of real telemetry")
 
+	fuelPerLap := flag.Float64("fuel-per-lap", 0, "Expected fuel consumption per lap used before the first lap is completed")
+	track := flag.String("track", "", "Name of the track, used to find laps of previous races")
+	fuelAverage := flag.String("fuel-average", lib.AverageMean, fmt.Sprintf("Method to average the fuel consumption, one of %v", lib.AveragingMethods))
+	fuelMultiplier := flag.Float64("fuel-multiplier", 1, "Fuel multiplier of the lobby")
+	tireMultiplier := flag.Float64("tire-multiplier", 1, "Tire wear multiplier of the lobby")
+	tireTempMin := flag.Float64("tire-temp-min", float64(lib.NewTemperatureWindow().Min), "Lower end of the optimal tire temperature window in °C")
+	tireTempMax := flag.Float64("tire-temp-max", float64(lib.NewTemperatureWindow().Max), "Upper end of the optimal tire temperature window in °C")
+	speedTrapsFlag := flag.String("speed-traps", "", "Speed traps as name:distance in meters from the start line separated by commas, by default they are placed before heavy braking zones")
+	lapColumnsFlag := flag.String("lap-columns", "", fmt.Sprintf("Columns of the lap table separated by commas, by default all of %v", lib.LapTableColumns))
+	languageFlag := flag.String("language", lib.LanguageEnglish, fmt.Sprintf("Language of the dashboard, one of %v", lib.Languages))
+	includeIncidentLapsFlag := flag.Bool("include-incident-laps", false, "Include laps with incidents like spins or collisions in averages and the lap time deviation")
+	sessionDirFlag := flag.String("session-dir", ".", "Directory with dump files of stored sessions to compare with")
+	storeDir := flag.String("store-dir", defaultStoreDir(), "Directory to store laps of previous races in, empty to disable")
+	webDirFlag := flag.String("web-dir", "", "Directory to serve the web assets from instead of the embedded ones, for development")
+
 	// Parse command-line flags
 	flag.Parse()
+	sessionDir = *sessionDirFlag
+	webDir = *webDirFlag
+	includeIncidentLaps = *includeIncidentLapsFlag
+
+	speedTraps, err := lib.ParseSpeedTraps(*speedTrapsFlag)
+	if err != nil {
+		log.Fatalf("Error parsing speed traps: %v", err)
+	}
+	lapColumns, err := lib.ParseLapTableColumns(*lapColumnsFlag)
+	if err != nil {
+		log.Fatalf("Error parsing lap table columns: %v", err)
+	}
+	language, err := lib.ParseLanguage(*languageFlag)
+	if err != nil {
+		log.Fatalf("Error parsing language: %v", err)
+	}
 
 	fmt.Printf("Version: https://github.com/snipem/gt7fuel/commit/%s\n", GitCommit)
 
 	for {
-		run(*raceTime, *parseTwitch, *twitchUrl, *dumpFile)
+		run(*raceTime, *parseTwitch, *twitchUrl, *dumpFile, float32(*fuelPerLap), *track, *storeDir, *fuelAverage, lib.RaceSettings{
+			FuelMultiplier:  float32(*fuelMultiplier),
+			TireMultiplier:  float32(*tireMultiplier),
+			TireTempWindow:  lib.TemperatureWindow{Min: float32(*tireTempMin), Max: float32(*tireTempMax)},
+			SpeedTraps:      speedTraps,
+			LapTableColumns: lapColumns,
+			Language:        language,
+		})
 		log.Println("Sleeping 10 seconds ...")
 		time.Sleep(10 * time.Second)
 	}
 
 }
 
-func run(raceTime int, parseTwitch bool, twitchResource string, dumpFilePath string) {
+func defaultStoreDir() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return path.Join(configDir, "gt7fuel")
+}
+
+func run(raceTime int, parseTwitch bool, twitchResource string, dumpFilePath string, fuelPerLap float32, track string, storeDir string, fuelAverage string, settings lib.RaceSettings) {
 
 	// set global var from parameter
 	raceTimeInMinutes = raceTime
@@ -232,6 +933,29 @@ func run(raceTime int, parseTwitch bool, twitchResource string, dumpFilePath str
 	}
 
 	gt7stats = lib.NewStats()
+	gt7stats.Track = track
+	gt7stats.Settings = settings
+	gt7stats.IncludeIncidentLaps = includeIncidentLaps
+
+	err := gt7stats.SetAveragingMethod(fuelAverage)
+	if err != nil {
+		log.Fatalf("Error setting averaging method: %v", err)
+	}
+
+	if fuelPerLap > 0 {
+		gt7stats.SetFuelPrior(fuelPerLap)
+	}
+
+	if storeDir != "" && dumpFilePath != "" {
+		log.Println("Not storing laps of dump file")
+	} else if storeDir != "" {
+		store, err := lib.NewStore(storeDir)
+		if err != nil {
+			log.Printf("Not storing laps: %v", err)
+		} else {
+			gt7stats.Store = store
+		}
+	}
 
 	if parseTwitch {
 		log.Printf("Parsing Twitch for Tire Data")
@@ -247,7 +971,7 @@ func run(raceTime int, parseTwitch bool, twitchResource string, dumpFilePath str
 
 	go stayAwakeIfConnectionActive(gt7stats)
 
-	err := open(localurl)
+	err = open(localurl)
 	if err != nil {
 		log.Fatalf("Error opening browser: %v", err)
 	}
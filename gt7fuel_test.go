@@ -4,6 +4,11 @@ import (
 	gt7 "github.com/snipem/go-gt7-telemetry/lib"
 	"github.com/snipem/gt7fuel/lib"
 	"github.com/snipem/gt7tools/lib/dump"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -40,3 +45,43 @@ func Benchmark_Run(b *testing.B) {
 	//}
 
 }
+
+func TestHomePage(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	homePage(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "<script>")
+	assert.NotContains(t, recorder.Body.String(), "localhost:9100")
+	assert.Equal(t, "no-cache", recorder.Header().Get("Cache-Control"))
+	etag := recorder.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// unknown paths show the dashboard as well
+	recorder = httptest.NewRecorder()
+	homePage(recorder, httptest.NewRequest(http.MethodGet, "/static", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, etag, recorder.Header().Get("ETag"))
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("If-None-Match", etag)
+	recorder = httptest.NewRecorder()
+	homePage(recorder, request)
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+}
+
+func TestHomePageFromWebDir(t *testing.T) {
+	webDir = t.TempDir()
+	defer func() { webDir = "" }()
+	assert.NoError(t, os.WriteFile(filepath.Join(webDir, "index.html"), []byte("<h1>dev</h1>"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(webDir, "style.css"), []byte("h1 {}"), 0644))
+
+	recorder := httptest.NewRecorder()
+	homePage(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "<h1>dev</h1>", recorder.Body.String())
+	assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+
+	recorder = httptest.NewRecorder()
+	homePage(recorder, httptest.NewRequest(http.MethodGet, "/style.css", nil))
+	assert.Equal(t, "h1 {}", recorder.Body.String())
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "text/css")
+}
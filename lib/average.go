@@ -0,0 +1,150 @@
+package lib
+
+import (
+	"fmt"
+	"github.com/montanaflynn/stats"
+	"math"
+	"time"
+)
+
+const AverageMean = "mean"
+const AverageRolling = "rolling"
+const AverageExponential = "exponential"
+const AverageMedian = "median"
+const AverageTrimmed = "trimmed"
+
+var AveragingMethods = []string{AverageMean, AverageRolling, AverageExponential, AverageMedian, AverageTrimmed}
+
+// AveragingSettings selects how the fuel consumption of the driven laps is averaged
+type AveragingSettings struct {
+	Method string
+	// Window is the number of last laps used by the rolling average
+	Window int
+	// Alpha is the weight of the newest lap for the exponential average
+	Alpha float64
+	// OutlierThreshold is the lap time deviation in standard deviations a lap may have
+	// before it is ignored by the trimmed average
+	OutlierThreshold float64
+}
+
+func NewAveragingSettings() AveragingSettings {
+	return AveragingSettings{
+		Method:           AverageMean,
+		Window:           5,
+		Alpha:            0.3,
+		OutlierThreshold: 1.5,
+	}
+}
+
+// SetAveragingMethod sets the method used for the average fuel consumption
+func (s *Stats) SetAveragingMethod(method string) error {
+	for _, m := range AveragingMethods {
+		if m == method {
+			s.Averaging.Method = method
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown averaging method %s, use one of %v", method, AveragingMethods)
+}
+
+func getAverageFuelConsumption(laps []Lap, settings AveragingSettings) (float32, float32, error) {
+	accountableLaps := getAccountableLaps(laps)
+	if len(accountableLaps) == 0 {
+		return -1, -1, fmt.Errorf("no accountable laps found")
+	}
+
+	switch settings.Method {
+	case AverageMean, "":
+		return getMeanAndDeviation(getFuelConsumed(accountableLaps))
+	case AverageRolling:
+		if settings.Window > 0 && len(accountableLaps) > settings.Window {
+			accountableLaps = accountableLaps[len(accountableLaps)-settings.Window:]
+		}
+		return getMeanAndDeviation(getFuelConsumed(accountableLaps))
+	case AverageExponential:
+		return getExponentialAverageAndDeviation(getFuelConsumed(accountableLaps), settings.Alpha)
+	case AverageMedian:
+		data := getFuelConsumed(accountableLaps)
+		median, err := data.Median()
+		if err != nil {
+			return -1, -1, err
+		}
+		mad, err := data.MedianAbsoluteDeviation()
+		if err != nil {
+			return -1, -1, err
+		}
+		return float32(median), float32(mad), nil
+	case AverageTrimmed:
+		return getMeanAndDeviation(getFuelConsumed(removeLapTimeOutliers(accountableLaps, settings.OutlierThreshold)))
+	}
+	return -1, -1, fmt.Errorf("unknown averaging method %s", settings.Method)
+}
+
+func getFuelConsumed(laps []Lap) stats.Float64Data {
+	fuelConsumed := stats.Float64Data{}
+	for _, lap := range laps {
+		fuelConsumed = append(fuelConsumed, float64(lap.GetFuelConsumed()))
+	}
+	return fuelConsumed
+}
+
+func getMeanAndDeviation(data stats.Float64Data) (float32, float32, error) {
+	mean, err := data.Mean()
+	if err != nil {
+		return -1, -1, err
+	}
+	deviation, err := data.StandardDeviation()
+	if err != nil {
+		return -1, -1, err
+	}
+	return float32(mean), float32(deviation), nil
+}
+
+// getExponentialAverageAndDeviation weights the newest value with alpha and the older ones exponentially less
+func getExponentialAverageAndDeviation(values stats.Float64Data, alpha float64) (float32, float32, error) {
+	if len(values) == 0 {
+		return -1, -1, fmt.Errorf("no values to average")
+	}
+	if alpha <= 0 || alpha > 1 {
+		return -1, -1, fmt.Errorf("alpha has to be in (0, 1], is %f", alpha)
+	}
+
+	average := values[0]
+	variance := float64(0)
+	for _, v := range values[1:] {
+		diff := v - average
+		average += alpha * diff
+		variance = (1 - alpha) * (variance + alpha*diff*diff)
+	}
+	return float32(average), float32(math.Sqrt(variance)), nil
+}
+
+// removeLapTimeOutliers removes laps with a lap time too far off the median lap time,
+// e.g. laps with a spin or laps in a slipstream train
+func removeLapTimeOutliers(laps []Lap, threshold float64) []Lap {
+	if len(laps) < 3 {
+		return laps
+	}
+
+	lapTimes := []time.Duration{}
+	for _, lap := range laps {
+		lapTimes = append(lapTimes, lap.Duration)
+	}
+	data := stats.LoadRawData(lapTimes)
+	median, err := data.Median()
+	if err != nil {
+		return laps
+	}
+	deviation, err := data.StandardDeviation()
+	if err != nil || deviation == 0 {
+		return laps
+	}
+
+	lapsWithoutOutliers := []Lap{}
+	for _, lap := range laps {
+		if math.Abs(float64(lap.Duration)-median) <= threshold*deviation {
+			lapsWithoutOutliers = append(lapsWithoutOutliers, lap)
+		}
+	}
+	return lapsWithoutOutliers
+}
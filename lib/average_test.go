@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func getLapsWithConsumption(consumption []float32, durations []time.Duration) []Lap {
+	laps := []Lap{}
+	for i := range consumption {
+		laps = append(laps, Lap{Number: int16(i + 1), FuelStart: 100, FuelEnd: 100 - consumption[i], Duration: durations[i]})
+	}
+	return laps
+}
+
+func Test_getAverageFuelConsumptionWithMethod(t *testing.T) {
+	minute := time.Minute
+	laps := getLapsWithConsumption(
+		[]float32{2, 3, 3, 8, 4},
+		[]time.Duration{minute, minute, minute + time.Second, 2 * minute, minute},
+	)
+
+	t.Run("Mean", func(t *testing.T) {
+		avg, spread, err := getAverageFuelConsumption(laps, AveragingSettings{Method: AverageMean})
+		assert.NoError(t, err)
+		assert.Equal(t, float32(4), avg)
+		assert.InDelta(t, 2.097, spread, 0.001)
+	})
+
+	t.Run("Rolling", func(t *testing.T) {
+		avg, _, err := getAverageFuelConsumption(laps, AveragingSettings{Method: AverageRolling, Window: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, float32(6), avg)
+	})
+
+	t.Run("Exponential", func(t *testing.T) {
+		avg, spread, err := getAverageFuelConsumption(laps, AveragingSettings{Method: AverageExponential, Alpha: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, float32(4), avg, "alpha of 1 only takes the newest lap")
+		assert.Equal(t, float32(0), spread)
+
+		avg, _, err = getAverageFuelConsumption(laps, AveragingSettings{Method: AverageExponential, Alpha: 0.5})
+		assert.NoError(t, err)
+		assert.Equal(t, float32(4.6875), avg)
+
+		_, _, err = getAverageFuelConsumption(laps, AveragingSettings{Method: AverageExponential, Alpha: 0})
+		assert.Error(t, err)
+	})
+
+	t.Run("Median", func(t *testing.T) {
+		avg, spread, err := getAverageFuelConsumption(laps, AveragingSettings{Method: AverageMedian})
+		assert.NoError(t, err)
+		assert.Equal(t, float32(3), avg)
+		assert.Equal(t, float32(1), spread)
+	})
+
+	t.Run("Trimmed", func(t *testing.T) {
+		// the lap with 2 minutes is a spin and is removed
+		avg, _, err := getAverageFuelConsumption(laps, AveragingSettings{Method: AverageTrimmed, OutlierThreshold: 1.5})
+		assert.NoError(t, err)
+		assert.Equal(t, float32(3), avg)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, _, err := getAverageFuelConsumption(laps, AveragingSettings{Method: "unknown"})
+		assert.Error(t, err)
+	})
+
+	t.Run("No laps", func(t *testing.T) {
+		_, _, err := getAverageFuelConsumption([]Lap{}, NewAveragingSettings())
+		assert.Error(t, err)
+	})
+}
+
+func TestStats_SetAveragingMethod(t *testing.T) {
+	s := NewStats()
+	assert.Equal(t, AverageMean, s.Averaging.Method)
+	assert.NoError(t, s.SetAveragingMethod(AverageMedian))
+	assert.Equal(t, AverageMedian, s.Averaging.Method)
+	assert.Error(t, s.SetAveragingMethod("unknown"))
+	assert.Equal(t, AverageMedian, s.Averaging.Method)
+}
@@ -0,0 +1,187 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// brakeThreshold is the brake pressure in percent that starts a braking zone
+const brakeThreshold = 5
+
+// minBrakingZoneGap is the distance in meters without braking that separates two braking zones
+const minBrakingZoneGap = 30
+
+// cornerMatchDistance is the maximum distance in meters between the brake points of two laps to count as the same corner
+const cornerMatchDistance = 100
+
+// brakePointTolerance is the difference in meters to the best lap's brake point that is not worth a feedback
+const brakePointTolerance = 5
+
+// abruptReleaseRatio is the share of the best lap's release duration below which the release counts as too abrupt
+const abruptReleaseRatio = 0.5
+
+type BrakingZone struct {
+	// Corner is the number of the braking zone in the lap, starting with 1
+	Corner int `json:"corner"`
+	// Start is the brake point as distance from the start of the lap in meters
+	Start        float32 `json:"start"`
+	End          float32 `json:"end"`
+	PeakPressure float32 `json:"peak_pressure"`
+	// ReleaseDuration is the time from leaving the peak pressure until the brake is released
+	ReleaseDuration time.Duration `json:"release_duration"`
+	MinSpeed        float32       `json:"min_speed"`
+	// PositionX and PositionZ are the coordinates of the brake point
+	PositionX float32 `json:"position_x"`
+	PositionZ float32 `json:"position_z"`
+
+	// startIndex and endIndex are the indices of the first and last braking package in the data history of the lap
+	startIndex int
+	endIndex   int
+}
+
+type CornerFeedback struct {
+	Corner   int      `json:"corner"`
+	Feedback []string `json:"feedback"`
+}
+
+// GetBrakingZones returns the braking zones of the lap, finished laps keep them from when they were finished
+func GetBrakingZones(lap Lap) []BrakingZone {
+	if lap.brakingZones != nil {
+		return lap.brakingZones
+	}
+	return segmentBrakingZones(lap)
+}
+
+// segmentBrakingZones segments the lap into braking zones by track position
+func segmentBrakingZones(lap Lap) []BrakingZone {
+	zones := []BrakingZone{}
+	distances := lap.GetDistances()
+
+	var zone *BrakingZone
+	peakIndex := 0
+	lastBrakingIndex := 0
+
+	closeZone := func() {
+		zone.End = distances[lastBrakingIndex]
+		zone.endIndex = lastBrakingIndex
+		zone.ReleaseDuration = packageNumbersToDuration(lap.DataHistory[lastBrakingIndex].PackageID - lap.DataHistory[peakIndex].PackageID)
+		zone.Corner = len(zones) + 1
+		zones = append(zones, *zone)
+		zone = nil
+	}
+
+	for i, data := range lap.DataHistory {
+		braking := data.Brake > brakeThreshold
+
+		if zone != nil && !braking && distances[i]-distances[lastBrakingIndex] > minBrakingZoneGap {
+			closeZone()
+		}
+
+		if !braking {
+			if zone != nil && data.CarSpeed < zone.MinSpeed {
+				// the apex is usually reached after the brake has been released
+				zone.MinSpeed = data.CarSpeed
+			}
+			continue
+		}
+
+		if zone == nil {
+			zone = &BrakingZone{
+				Start:      distances[i],
+				MinSpeed:   data.CarSpeed,
+				PositionX:  data.PositionX,
+				PositionZ:  data.PositionZ,
+				startIndex: i,
+			}
+			peakIndex = i
+		}
+		// the release starts with the last package at peak pressure
+		if data.Brake >= zone.PeakPressure {
+			zone.PeakPressure = data.Brake
+			peakIndex = i
+		}
+		if data.CarSpeed < zone.MinSpeed {
+			zone.MinSpeed = data.CarSpeed
+		}
+		lastBrakingIndex = i
+	}
+
+	if zone != nil {
+		closeZone()
+	}
+	return zones
+}
+
+// matchBrakingZone returns the braking zone of the reference lap closest to the brake point of the zone
+func matchBrakingZone(zone BrakingZone, referenceZones []BrakingZone) (BrakingZone, error) {
+	bestMatch := BrakingZone{}
+	bestDistance := float32(math.MaxFloat32)
+	for _, reference := range referenceZones {
+		distance := float32(math.Abs(float64(zone.Start - reference.Start)))
+		if distance < bestDistance {
+			bestDistance = distance
+			bestMatch = reference
+		}
+	}
+	if bestDistance > cornerMatchDistance {
+		return BrakingZone{}, fmt.Errorf("no braking zone of the reference lap near %.0fm", zone.Start)
+	}
+	return bestMatch, nil
+}
+
+// CompareBrakingZones gives feedback for every braking zone compared to the braking zones of the best lap.
+// Corners are numbered like in the best lap
+func CompareBrakingZones(zones []BrakingZone, bestZones []BrakingZone) []CornerFeedback {
+	feedbacks := []CornerFeedback{}
+	for _, zone := range zones {
+		best, err := matchBrakingZone(zone, bestZones)
+		if err != nil {
+			feedbacks = append(feedbacks, CornerFeedback{
+				Corner:   -1,
+				Feedback: []string{fmt.Sprintf("braked at %.0fm where the best lap did not brake", zone.Start)},
+			})
+			continue
+		}
+		feedbacks = append(feedbacks, CornerFeedback{Corner: best.Corner, Feedback: compareBrakingZone(zone, best)})
+	}
+	return feedbacks
+}
+
+func compareBrakingZone(zone BrakingZone, best BrakingZone) []string {
+	feedback := []string{}
+
+	brakePointDelta := zone.Start - best.Start
+	if brakePointDelta < -brakePointTolerance {
+		feedback = append(feedback, fmt.Sprintf("braked %.0fm earlier than best", -brakePointDelta))
+	} else if brakePointDelta > brakePointTolerance {
+		feedback = append(feedback, fmt.Sprintf("braked %.0fm later than best", brakePointDelta))
+	}
+
+	if zone.PeakPressure < best.PeakPressure-10 {
+		feedback = append(feedback, fmt.Sprintf("peak pressure %.0f%% lower than best", best.PeakPressure-zone.PeakPressure))
+	}
+
+	if best.ReleaseDuration > 0 && float64(zone.ReleaseDuration) < abruptReleaseRatio*float64(best.ReleaseDuration) {
+		feedback = append(feedback, "release too abrupt")
+	}
+
+	if zone.MinSpeed < best.MinSpeed-3 {
+		feedback = append(feedback, fmt.Sprintf("minimum speed %.0f km/h lower than best", best.MinSpeed-zone.MinSpeed))
+	}
+
+	return feedback
+}
+
+// GetBrakingFeedback compares the braking zones of the last lap with the ones of the best lap
+func (s *Stats) GetBrakingFeedback() ([]CornerFeedback, error) {
+	if len(s.Laps) == 0 {
+		return []CornerFeedback{}, fmt.Errorf("no lap driven yet")
+	}
+	bestLap, err := s.GetBestLap()
+	if err != nil {
+		return []CornerFeedback{}, fmt.Errorf("error getting best lap: %v", err)
+	}
+	lastLap := s.Laps[len(s.Laps)-1]
+	return CompareBrakingZones(GetBrakingZones(lastLap), GetBrakingZones(bestLap)), nil
+}
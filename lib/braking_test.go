@@ -0,0 +1,118 @@
+package lib
+
+import (
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// getLapWithBrakingZones drives with 180 km/h and brakes at the given packages. Brake pressure rises
+// to 100 within 10 packages, holds for 50 packages and is released over releasePackages
+func getLapWithBrakingZones(duration time.Duration, brakeAt []int32, releasePackages int32) Lap {
+	lap := Lap{Number: 2, Duration: duration}
+	for i := int32(0); i < 3000; i++ {
+		data := gt7.GTData{PackageID: 1000 + i, CarSpeed: 180}
+		for _, start := range brakeAt {
+			p := i - start
+			switch {
+			case p < 0:
+			case p < 10:
+				data.Brake = float32(p+1) * 10
+			case p < 60:
+				data.Brake = 100
+			case p < 60+releasePackages:
+				data.Brake = 100 - float32(p-59)*100/float32(releasePackages)
+			}
+			if p >= 0 && p < 60+releasePackages+20 {
+				data.CarSpeed = 180 - float32(p)
+				if data.CarSpeed < 80 {
+					data.CarSpeed = 80
+				}
+			}
+		}
+		lap.DataHistory = append(lap.DataHistory, data)
+	}
+	return lap
+}
+
+func TestGetBrakingZones(t *testing.T) {
+	lap := getLapWithBrakingZones(time.Minute, []int32{500, 1500}, 40)
+	zones := GetBrakingZones(lap)
+	assert.Len(t, zones, 2)
+
+	assert.Equal(t, 1, zones[0].Corner)
+	assert.Equal(t, 2, zones[1].Corner)
+	// 500 packages with 180 km/h are 400m
+	assert.InDelta(t, 400, zones[0].Start, 1)
+	assert.Equal(t, float32(100), zones[0].PeakPressure)
+	assert.Equal(t, float32(80), zones[0].MinSpeed)
+	assert.Greater(t, zones[0].End, zones[0].Start)
+	// pressure is below the threshold after 37 of 40 release packages
+	assert.Equal(t, packageNumbersToDuration(37), zones[0].ReleaseDuration)
+
+	assert.Len(t, GetBrakingZones(Lap{}), 0)
+}
+
+func TestGetBrakingZonesOfFinishedLap(t *testing.T) {
+	lap := getLapWithBrakingZones(time.Minute, []int32{500, 1500}, 40)
+	lap.brakingZones = segmentBrakingZones(lap)
+	// the zones of a finished lap are not segmented again
+	lap.DataHistory = nil
+	assert.Len(t, GetBrakingZones(lap), 2)
+}
+
+func TestCompareBrakingZones(t *testing.T) {
+	best := GetBrakingZones(getLapWithBrakingZones(time.Minute, []int32{500, 1500}, 40))
+
+	t.Run("Same as best", func(t *testing.T) {
+		feedback := CompareBrakingZones(best, best)
+		assert.Equal(t, []CornerFeedback{{Corner: 1, Feedback: []string{}}, {Corner: 2, Feedback: []string{}}}, feedback)
+	})
+
+	t.Run("Earlier and abrupt", func(t *testing.T) {
+		// 30 packages with 180 km/h are 24m
+		zones := GetBrakingZones(getLapWithBrakingZones(time.Minute, []int32{470, 1500}, 1))
+		feedback := CompareBrakingZones(zones, best)
+		assert.Equal(t, 1, feedback[0].Corner)
+		assert.Contains(t, feedback[0].Feedback, "braked 24m earlier than best")
+		assert.Contains(t, feedback[0].Feedback, "release too abrupt")
+	})
+
+	t.Run("Unknown corner", func(t *testing.T) {
+		zones := GetBrakingZones(getLapWithBrakingZones(time.Minute, []int32{1000}, 40))
+		feedback := CompareBrakingZones(zones, best)
+		assert.Equal(t, -1, feedback[0].Corner)
+	})
+}
+
+func TestStats_GetBrakingFeedback(t *testing.T) {
+	s := NewStats()
+	_, err := s.GetBrakingFeedback()
+	assert.Error(t, err)
+
+	s.Laps = []Lap{
+		getLapWithBrakingZones(time.Minute, []int32{500}, 40),
+		getLapWithBrakingZones(time.Minute+time.Second, []int32{480}, 40),
+	}
+	s.Laps[1].Number = 3
+
+	feedback, err := s.GetBrakingFeedback()
+	assert.NoError(t, err)
+	assert.Equal(t, []CornerFeedback{{Corner: 1, Feedback: []string{"braked 16m earlier than best"}}}, feedback)
+}
+
+func Test_getBestLap(t *testing.T) {
+	_, err := getBestLap([]Lap{{Number: 1, Duration: time.Minute}})
+	assert.Error(t, err)
+
+	data := []gt7.GTData{{}}
+	bestLap, err := getBestLap([]Lap{
+		{Number: 1, Duration: time.Minute, DataHistory: data},
+		{Number: 2, Duration: 2 * time.Minute, DataHistory: data},
+		{Number: 3, Duration: 90 * time.Second, DataHistory: data},
+		{Number: 4, Duration: 80 * time.Second},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int16(3), bestLap.Number)
+}
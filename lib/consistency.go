@@ -0,0 +1,156 @@
+package lib
+
+import (
+	"fmt"
+	"github.com/montanaflynn/stats"
+	"time"
+)
+
+// consistencyWindow is the number of consecutive laps the rolling deviation and the windows are measured over
+const consistencyWindow = 5
+
+// consistencySectors is the number of sectors of equal length a lap is split into, since GT7 does not transmit sectors
+const consistencySectors = 3
+
+type LapDeviation struct {
+	// Lap is the last lap of the window
+	Lap       int16         `json:"lap"`
+	Deviation time.Duration `json:"deviation"`
+}
+
+type ConsistencyWindow struct {
+	FirstLap  int16         `json:"first_lap"`
+	LastLap   int16         `json:"last_lap"`
+	Deviation time.Duration `json:"deviation"`
+}
+
+type ConsistencyReport struct {
+	Deviation        time.Duration   `json:"deviation"`
+	RollingDeviation []LapDeviation  `json:"rolling_deviation"`
+	SectorDeviations []time.Duration `json:"sector_deviations"`
+	// DegradationSlope is the change of the lap time in seconds per lap, positive if the laps get slower
+	DegradationSlope float32 `json:"degradation_slope"`
+	// BestWindow and WorstWindow are nil as long as there are not enough laps for a window
+	BestWindow  *ConsistencyWindow `json:"best_window"`
+	WorstWindow *ConsistencyWindow `json:"worst_window"`
+}
+
+func getDurationDeviation(durations []time.Duration) (time.Duration, error) {
+	deviation, err := stats.LoadRawData(durations).StandardDeviation()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(deviation), nil
+}
+
+// getSectorTimes splits the lap into sectors of equal distance and returns the time spent in each
+func getSectorTimes(lap Lap, sectors int) ([]time.Duration, error) {
+	if len(lap.DataHistory) == 0 {
+		return nil, fmt.Errorf("no data in lap %d", lap.Number)
+	}
+	distances := lap.GetDistances()
+	sectorLength := distances[len(distances)-1] / float32(sectors)
+
+	times := []time.Duration{}
+	start := 0
+	for sector := 1; sector <= sectors; sector++ {
+		end := start
+		for end < len(distances)-1 && (sector == sectors || distances[end] < sectorLength*float32(sector)) {
+			end++
+		}
+		times = append(times, packageNumbersToDuration(lap.DataHistory[end].PackageID-lap.DataHistory[start].PackageID))
+		start = end
+	}
+	return times, nil
+}
+
+// getConsistencyWindows returns the deviation of every window of consecutive laps
+func getConsistencyWindows(laps []Lap) []ConsistencyWindow {
+	windows := []ConsistencyWindow{}
+	for i := consistencyWindow; i <= len(laps); i++ {
+		durations := []time.Duration{}
+		for _, lap := range laps[i-consistencyWindow : i] {
+			durations = append(durations, lap.Duration)
+		}
+		deviation, err := getDurationDeviation(durations)
+		if err != nil {
+			continue
+		}
+		windows = append(windows, ConsistencyWindow{
+			FirstLap:  laps[i-consistencyWindow].Number,
+			LastLap:   laps[i-1].Number,
+			Deviation: deviation,
+		})
+	}
+	return windows
+}
+
+func getConsistencyReport(laps []Lap) (ConsistencyReport, error) {
+	report := ConsistencyReport{RollingDeviation: []LapDeviation{}, SectorDeviations: []time.Duration{}}
+
+	regularLaps := []Lap{}
+	for _, lap := range laps {
+		if lap.IsRegularLap() {
+			regularLaps = append(regularLaps, lap)
+		}
+	}
+	if len(regularLaps) < 2 {
+		return report, fmt.Errorf("not enough regular laps for a consistency report, nr of laps: %d", len(regularLaps))
+	}
+
+	lapTimes, lapNumbers, seconds := []time.Duration{}, []float64{}, []float64{}
+	for _, lap := range regularLaps {
+		lapTimes = append(lapTimes, lap.Duration)
+		lapNumbers = append(lapNumbers, float64(lap.Number))
+		seconds = append(seconds, lap.Duration.Seconds())
+	}
+
+	deviation, err := getDurationDeviation(lapTimes)
+	if err != nil {
+		return report, err
+	}
+	report.Deviation = deviation
+
+	slope, err := getSlope(lapNumbers, seconds)
+	if err == nil {
+		report.DegradationSlope = float32(slope)
+	}
+
+	windows := getConsistencyWindows(regularLaps)
+	for i, window := range windows {
+		report.RollingDeviation = append(report.RollingDeviation, LapDeviation{Lap: window.LastLap, Deviation: window.Deviation})
+		if report.BestWindow == nil || window.Deviation < report.BestWindow.Deviation {
+			report.BestWindow = &windows[i]
+		}
+		if report.WorstWindow == nil || window.Deviation > report.WorstWindow.Deviation {
+			report.WorstWindow = &windows[i]
+		}
+	}
+
+	sectorTimes := make([][]time.Duration, consistencySectors)
+	for _, lap := range regularLaps {
+		times, err := getSectorTimes(lap, consistencySectors)
+		if err != nil {
+			continue
+		}
+		for sector, t := range times {
+			sectorTimes[sector] = append(sectorTimes[sector], t)
+		}
+	}
+	for _, times := range sectorTimes {
+		if len(times) < 2 {
+			break
+		}
+		deviation, err := getDurationDeviation(times)
+		if err != nil {
+			break
+		}
+		report.SectorDeviations = append(report.SectorDeviations, deviation)
+	}
+	return report, nil
+}
+
+// GetConsistencyReport analyses the consistency of the regular laps without incidents
+func (s *Stats) GetConsistencyReport() (ConsistencyReport, error) {
+	return getConsistencyReport(s.getLapsForAverages())
+}
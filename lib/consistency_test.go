@@ -0,0 +1,59 @@
+package lib
+
+import (
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// getLapsWithDurations returns laps starting with lap 2 and the given lap times in seconds
+func getLapsWithDurations(seconds ...float64) []Lap {
+	laps := []Lap{}
+	for i, s := range seconds {
+		laps = append(laps, Lap{Number: int16(i + 2), Duration: time.Duration(s * float64(time.Second))})
+	}
+	return laps
+}
+
+func Test_getSectorTimes(t *testing.T) {
+	lap := Lap{Number: 2}
+	for i := 0; i < 301; i++ {
+		// the first 200 packages are driven with half the speed, so they cover half the lap
+		speed := float32(180)
+		if i < 200 {
+			speed = 90
+		}
+		lap.DataHistory = append(lap.DataHistory, gt7.GTData{PackageID: int32(i), CarSpeed: speed})
+	}
+	times, err := getSectorTimes(lap, 2)
+	assert.NoError(t, err)
+	assert.Len(t, times, 2)
+	assert.InDelta(t, packageNumbersToDuration(200), times[0], float64(packageNumbersToDuration(2)))
+	assert.Equal(t, packageNumbersToDuration(300), times[0]+times[1])
+
+	_, err = getSectorTimes(Lap{}, 2)
+	assert.Error(t, err)
+}
+
+func Test_getConsistencyReport(t *testing.T) {
+	_, err := getConsistencyReport(getLapsWithDurations(60))
+	assert.Error(t, err)
+
+	laps := getLapsWithDurations(60, 61, 60, 61, 60, 65, 58, 66, 57)
+	report, err := getConsistencyReport(laps)
+	assert.NoError(t, err)
+
+	assert.Greater(t, report.Deviation, time.Duration(0))
+	assert.Len(t, report.RollingDeviation, 5)
+	assert.Equal(t, int16(6), report.RollingDeviation[0].Lap)
+	assert.Equal(t, ConsistencyWindow{FirstLap: 2, LastLap: 6, Deviation: report.RollingDeviation[0].Deviation}, *report.BestWindow)
+	assert.Equal(t, int16(10), report.WorstWindow.LastLap)
+	assert.Greater(t, report.DegradationSlope, float32(0))
+	assert.Len(t, report.SectorDeviations, 0, "laps without data have no sectors")
+
+	report, err = getConsistencyReport(getLapsWithDurations(60, 61))
+	assert.NoError(t, err)
+	assert.Nil(t, report.BestWindow)
+	assert.Equal(t, float32(1), report.DegradationSlope)
+}
@@ -0,0 +1,170 @@
+package lib
+
+import (
+	"fmt"
+)
+
+// InstantConsumptionWindowInMeters is the distance the instant fuel consumption is measured over.
+// Fuel is only transmitted in coarse steps, so shorter windows get too noisy
+const InstantConsumptionWindowInMeters = 500
+
+// minConsumptionSamples is the number of samples needed to fit the consumption
+const minConsumptionSamples = 10
+
+// getFuelSlope fits a line through the fuel readings with least squares and returns the fuel consumed per unit of x.
+// Fitting all samples instead of taking the difference of the first and the last reading filters the quantization
+// of the fuel readings
+func getFuelSlope(x []float64, fuel []float64) (float64, error) {
+	if len(x) != len(fuel) {
+		return 0, fmt.Errorf("got %d x values for %d fuel readings", len(x), len(fuel))
+	}
+	if len(x) < minConsumptionSamples {
+		return 0, fmt.Errorf("not enough samples to measure consumption, nr of samples: %d", len(x))
+	}
+
+	slope, err := getSlope(x, fuel)
+	if err != nil {
+		return 0, fmt.Errorf("no progress in samples, impossible to measure consumption")
+	}
+
+	consumption := -slope
+	if consumption < 0 {
+		return 0, fmt.Errorf("fuel is increasing, car is refuelling")
+	}
+	return consumption, nil
+}
+
+// getSlope fits a line through the values with least squares and returns its slope
+func getSlope(x []float64, y []float64) (float64, error) {
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range x {
+		sumX += x[i]
+		sumY += y[i]
+		sumXY += x[i] * y[i]
+		sumXX += x[i] * x[i]
+	}
+	n := float64(len(x))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, fmt.Errorf("all x values are the same, impossible to fit a line")
+	}
+	return (n*sumXY - sumX*sumY) / denominator, nil
+}
+
+// getFirstIndexSince returns the first index of the history belonging to the package or any later package
+func (h *History) getFirstIndexSince(packageId int32) (int, error) {
+	i := len(h.PackageId)
+	for i > 0 && h.PackageId[i-1] >= packageId {
+		i--
+	}
+	if i == len(h.PackageId) {
+		return 0, fmt.Errorf("package %d not found in history", packageId)
+	}
+	return i, nil
+}
+
+// getFirstIndexWithinDistance returns the first index of the history within the distance to the latest sample
+func (h *History) getFirstIndexWithinDistance(distance float32) int {
+	if len(h.TravelledDistance) == 0 {
+		return 0
+	}
+	last := h.TravelledDistance[len(h.TravelledDistance)-1]
+	i := len(h.TravelledDistance) - 1
+	for i > 0 && last-h.TravelledDistance[i-1] <= distance {
+		i--
+	}
+	return i
+}
+
+// getFuelPerMeter returns the fuel consumed per meter driven from the index on
+func (h *History) getFuelPerMeter(from int) (float32, error) {
+	if len(h.Fuel) != len(h.TravelledDistance) {
+		return 0, fmt.Errorf("history of fuel and distance is not aligned")
+	}
+	x := []float64{}
+	fuel := []float64{}
+	for i := from; i < len(h.Fuel); i++ {
+		x = append(x, float64(h.TravelledDistance[i]))
+		fuel = append(fuel, float64(h.Fuel[i]))
+	}
+	consumption, err := getFuelSlope(x, fuel)
+	return float32(consumption), err
+}
+
+// getFuelPerSecond returns the fuel consumed per second from the index on
+func (h *History) getFuelPerSecond(from int) (float32, error) {
+	if len(h.Fuel) != len(h.PackageId) {
+		return 0, fmt.Errorf("history of fuel and packages is not aligned")
+	}
+	x := []float64{}
+	fuel := []float64{}
+	for i := from; i < len(h.Fuel); i++ {
+		x = append(x, packageNumbersToDuration(h.PackageId[i]-h.PackageId[from]).Seconds())
+		fuel = append(fuel, float64(h.Fuel[i]))
+	}
+	consumption, err := getFuelSlope(x, fuel)
+	return float32(consumption), err
+}
+
+// GetInstantFuelPerMeter returns the fuel consumed per meter over the last InstantConsumptionWindowInMeters
+func (h *History) GetInstantFuelPerMeter() (float32, error) {
+	return h.getFuelPerMeter(h.getFirstIndexWithinDistance(InstantConsumptionWindowInMeters))
+}
+
+// GetInstantFuelPerSecond returns the fuel consumed per second over the last InstantConsumptionWindowInMeters
+func (h *History) GetInstantFuelPerSecond() (float32, error) {
+	return h.getFuelPerSecond(h.getFirstIndexWithinDistance(InstantConsumptionWindowInMeters))
+}
+
+// GetFuelPerMeterSince returns the fuel consumed per meter since the given package
+func (h *History) GetFuelPerMeterSince(packageId int32) (float32, error) {
+	from, err := h.getFirstIndexSince(packageId)
+	if err != nil {
+		return 0, err
+	}
+	return h.getFuelPerMeter(from)
+}
+
+// GetInstantFuelConsumptionPerLap returns the current fuel consumption scaled to a full lap
+func (s *Stats) GetInstantFuelConsumptionPerLap() (float32, error) {
+	fuelPerMeter, err := s.History.GetInstantFuelPerMeter()
+	if err != nil {
+		return -1, fmt.Errorf("error getting instant fuel consumption: %v", err)
+	}
+	return s.scaleFuelPerMeterToLap(fuelPerMeter)
+}
+
+// GetInstantFuelConsumptionPerMinute returns the current fuel consumption per minute
+func (s *Stats) GetInstantFuelConsumptionPerMinute() (float32, error) {
+	fuelPerSecond, err := s.History.GetInstantFuelPerSecond()
+	if err != nil {
+		return -1, fmt.Errorf("error getting instant fuel consumption: %v", err)
+	}
+	return fuelPerSecond * 60, nil
+}
+
+// GetProjectedFuelConsumptionCurrentLap returns the fuel consumption of the ongoing lap projected to its end
+func (s *Stats) GetProjectedFuelConsumptionCurrentLap() (float32, error) {
+	if len(s.OngoingLap.DataHistory) == 0 {
+		return -1, fmt.Errorf("no data in ongoing lap")
+	}
+	fuelPerMeter, err := s.History.GetFuelPerMeterSince(s.OngoingLap.DataHistory[0].PackageID)
+	if err != nil {
+		return -1, fmt.Errorf("error getting fuel consumption of ongoing lap: %v", err)
+	}
+	return s.scaleFuelPerMeterToLap(fuelPerMeter)
+}
+
+func (s *Stats) scaleFuelPerMeterToLap(fuelPerMeter float32) (float32, error) {
+	var prior *FuelPrior
+	p, err := s.GetFuelPrior()
+	if err == nil {
+		prior = &p
+	}
+
+	lapDistance, err := s.getLapDistance(prior)
+	if err != nil {
+		return -1, err
+	}
+	return fuelPerMeter * lapDistance, nil
+}
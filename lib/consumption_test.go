@@ -0,0 +1,86 @@
+package lib
+
+import (
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+// getHistoryWithQuantizedFuel drives with 100 km/h and consumes fuelPerKm, fuel is transmitted in steps of 0.1
+func getHistoryWithQuantizedFuel(packages int32, fuelPerKm float64) *History {
+	h := &History{}
+	for i := int32(0); i < packages; i++ {
+		distanceInKm := float64(packageNumbersToDuration(i).Hours()) * 100
+		fuel := 100 - distanceInKm*fuelPerKm
+		h.Update(gt7.GTData{PackageID: i, CarSpeed: 100, CurrentFuel: float32(math.Floor(fuel*10) / 10)})
+	}
+	return h
+}
+
+func Test_getFuelSlope(t *testing.T) {
+	t.Run("Linear", func(t *testing.T) {
+		slope, err := getFuelSlope([]float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, []float64{10, 9, 8, 7, 6, 5, 4, 3, 2, 1})
+		assert.NoError(t, err)
+		assert.InDelta(t, 1, slope, 0.0001)
+	})
+
+	t.Run("Refuelling", func(t *testing.T) {
+		_, err := getFuelSlope([]float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+		assert.Error(t, err)
+	})
+
+	t.Run("Standing still", func(t *testing.T) {
+		_, err := getFuelSlope([]float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, []float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1})
+		assert.Error(t, err)
+	})
+
+	t.Run("Not enough samples", func(t *testing.T) {
+		_, err := getFuelSlope([]float64{0, 1}, []float64{1, 0})
+		assert.Error(t, err)
+	})
+}
+
+func TestHistory_GetInstantFuelPerMeter(t *testing.T) {
+	// 2 km driven
+	h := getHistoryWithQuantizedFuel(4500, 5)
+
+	fuelPerMeter, err := h.GetInstantFuelPerMeter()
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.005, fuelPerMeter, 0.0002)
+
+	fuelPerSecond, err := h.GetInstantFuelPerSecond()
+	assert.NoError(t, err)
+	// 100 km/h are 1/36 km per second
+	assert.InDelta(t, 5.0/36, fuelPerSecond, 0.005)
+
+	fuelPerMeter, err = h.GetFuelPerMeterSince(2250)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.005, fuelPerMeter, 0.0002)
+
+	_, err = h.GetFuelPerMeterSince(5000)
+	assert.Error(t, err)
+}
+
+func TestStats_GetProjectedFuelConsumptionCurrentLap(t *testing.T) {
+	s := NewStats()
+	s.History = getHistoryWithQuantizedFuel(4500, 5)
+	s.OngoingLap.DataHistory = []gt7.GTData{{PackageID: 2250}}
+
+	_, err := s.GetProjectedFuelConsumptionCurrentLap()
+	assert.Error(t, err, "lap distance is unknown without driven laps or a prior")
+
+	s.FuelPrior = &FuelPrior{ConsumptionPerLap: 10, LapDistance: 5000, Source: PriorSourceUser}
+
+	projected, err := s.GetProjectedFuelConsumptionCurrentLap()
+	assert.NoError(t, err)
+	assert.InDelta(t, 25, projected, 1)
+
+	instant, err := s.GetInstantFuelConsumptionPerLap()
+	assert.NoError(t, err)
+	assert.InDelta(t, 25, instant, 1)
+
+	perMinute, err := s.GetInstantFuelConsumptionPerMinute()
+	assert.NoError(t, err)
+	assert.InDelta(t, 5.0/36*60, perMinute, 0.3)
+}
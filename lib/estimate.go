@@ -0,0 +1,167 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const PriorSourceUser = "user"
+const PriorSourceStore = "history"
+
+// priorWeightInLaps is how many driven laps the prior is worth when blended with live data
+const priorWeightInLaps = 2
+
+// fullConfidenceAfterLaps is the number of driven laps after which the estimate is fully trusted
+const fullConfidenceAfterLaps = 3
+
+// minPartialLapProgress is the lap progress needed before the ongoing lap is taken into account,
+// before that the quantized fuel readings are too coarse
+const minPartialLapProgress = 0.1
+
+// FuelPrior is the fuel consumption expected before any lap of the race has been driven
+type FuelPrior struct {
+	ConsumptionPerLap float32
+	LapDuration       time.Duration
+	LapDistance       float32
+	Source            string
+}
+
+type FuelEstimate struct {
+	ConsumptionPerLap float32
+	// Confidence grows from 0 to 1 with the number of laps driven
+	Confidence float32
+	Source     string
+}
+
+// getFuelPriorFromStoredLaps averages the regular laps of previous races,
+// the fuel consumption is scaled from the multiplier of the stored lap to the given one
+func getFuelPriorFromStoredLaps(storedLaps []StoredLap, fuelMultiplier float32) (FuelPrior, error) {
+	var fuelConsumed float32
+	var distance float32
+	var duration time.Duration
+	n := 0
+
+	for _, lap := range storedLaps {
+		// first lap is slower and pit laps have negative consumption
+		if lap.Number < 2 || lap.FuelConsumed <= 0 {
+			continue
+		}
+		fuelConsumed += lap.FuelConsumed / getMultiplier(lap.FuelMultiplier) * getMultiplier(fuelMultiplier)
+		distance += lap.Distance
+		duration += lap.Duration
+		n++
+	}
+
+	if n == 0 {
+		return FuelPrior{}, fmt.Errorf("no usable stored laps, nr of stored laps: %d", len(storedLaps))
+	}
+
+	return FuelPrior{
+		ConsumptionPerLap: fuelConsumed / float32(n),
+		LapDuration:       duration / time.Duration(n),
+		LapDistance:       distance / float32(n),
+		Source:            PriorSourceStore,
+	}, nil
+}
+
+// SetFuelPrior sets a user entered fuel consumption per lap
+func (s *Stats) SetFuelPrior(consumptionPerLap float32) {
+	s.FuelPrior = &FuelPrior{ConsumptionPerLap: consumptionPerLap, Source: PriorSourceUser}
+}
+
+// GetFuelPrior returns the user entered prior or if none is set the one from previous sessions
+func (s *Stats) GetFuelPrior() (FuelPrior, error) {
+	if s.FuelPrior != nil {
+		return *s.FuelPrior, nil
+	}
+
+	if s.Store == nil {
+		return FuelPrior{}, fmt.Errorf("no prior set and no store available")
+	}
+
+	storedLaps, err := s.getStoredLapsOfPreviousSessions()
+	if err != nil {
+		return FuelPrior{}, err
+	}
+	return getFuelPriorFromStoredLaps(storedLaps, s.Settings.FuelMultiplier)
+}
+
+// GetFuelConsumptionEstimate blends the prior with the consumption of driven laps and
+// of the ongoing lap so far. It is available before the first lap is completed
+func (s *Stats) GetFuelConsumptionEstimate() (FuelEstimate, error) {
+	var prior *FuelPrior
+	p, err := s.GetFuelPrior()
+	if err == nil {
+		prior = &p
+	}
+
+	partialFuel, partialProgress := s.getOngoingLapFuelAndProgress(prior)
+
+	return blendFuelConsumption(prior, GetAccountableFuelConsumption(s.getLapsForAverages()), partialFuel, partialProgress)
+}
+
+// getOngoingLapFuelAndProgress returns the fuel consumed in the ongoing lap and the share of the lap already driven
+func (s *Stats) getOngoingLapFuelAndProgress(prior *FuelPrior) (float32, float32) {
+	if len(s.OngoingLap.DataHistory) == 0 {
+		return 0, 0
+	}
+
+	lapDistance, err := s.getLapDistance(prior)
+	if err != nil {
+		return 0, 0
+	}
+
+	distanceInLap, err := s.History.GetTravelledDistanceSince(s.OngoingLap.DataHistory[0].PackageID)
+	if err != nil {
+		return 0, 0
+	}
+
+	return s.OngoingLap.FuelStart - s.LastData.CurrentFuel, distanceInLap / lapDistance
+}
+
+// getLapDistance returns the distance of the last lap or of the prior if no lap has been driven yet
+func (s *Stats) getLapDistance(prior *FuelPrior) (float32, error) {
+	if len(s.Laps) > 0 {
+		lapDistance := s.Laps[len(s.Laps)-1].GetDistance()
+		if lapDistance > 0 {
+			return lapDistance, nil
+		}
+	}
+	if prior != nil && prior.LapDistance > 0 {
+		return prior.LapDistance, nil
+	}
+	return 0, fmt.Errorf("lap distance unknown")
+}
+
+func blendFuelConsumption(prior *FuelPrior, lapConsumption []float32, partialFuel float32, partialProgress float32) (FuelEstimate, error) {
+
+	liveWeight := float32(len(lapConsumption))
+	liveFuel := float32(0)
+	for _, f := range lapConsumption {
+		liveFuel += f
+	}
+
+	// a pit stop in the ongoing lap makes the consumption negative
+	if partialProgress >= minPartialLapProgress && partialProgress <= 1 && partialFuel >= 0 {
+		liveWeight += partialProgress
+		liveFuel += partialFuel
+	}
+
+	if liveWeight == 0 && prior == nil {
+		return FuelEstimate{ConsumptionPerLap: -1}, fmt.Errorf("neither prior nor driven laps available")
+	}
+
+	confidence := float32(math.Min(1, float64(liveWeight)/fullConfidenceAfterLaps))
+
+	if prior == nil {
+		return FuelEstimate{ConsumptionPerLap: liveFuel / liveWeight, Confidence: confidence, Source: "live"}, nil
+	}
+
+	if liveWeight == 0 {
+		return FuelEstimate{ConsumptionPerLap: prior.ConsumptionPerLap, Confidence: 0, Source: prior.Source}, nil
+	}
+
+	blended := (prior.ConsumptionPerLap*priorWeightInLaps + liveFuel) / (priorWeightInLaps + liveWeight)
+	return FuelEstimate{ConsumptionPerLap: blended, Confidence: confidence, Source: prior.Source + "+live"}, nil
+}
@@ -0,0 +1,148 @@
+package lib
+
+import (
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_getFuelPriorFromStoredLaps(t *testing.T) {
+	t.Run("Regular laps", func(t *testing.T) {
+		prior, err := getFuelPriorFromStoredLaps([]StoredLap{
+			{Number: 1, FuelConsumed: 10, Duration: 3 * time.Minute, Distance: 5000}, // first lap does not count
+			{Number: 2, FuelConsumed: 4, Duration: 2 * time.Minute, Distance: 5000},
+			{Number: 3, FuelConsumed: -50, Duration: 3 * time.Minute, Distance: 5000}, // pit lap does not count
+			{Number: 4, FuelConsumed: 6, Duration: 2 * time.Minute, Distance: 5000},
+		}, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, float32(5), prior.ConsumptionPerLap)
+		assert.Equal(t, 2*time.Minute, prior.LapDuration)
+		assert.Equal(t, float32(5000), prior.LapDistance)
+		assert.Equal(t, PriorSourceStore, prior.Source)
+	})
+
+	t.Run("Different multipliers", func(t *testing.T) {
+		prior, err := getFuelPriorFromStoredLaps([]StoredLap{
+			{Number: 2, FuelConsumed: 4, FuelMultiplier: 2},
+			{Number: 3, FuelConsumed: 6, FuelMultiplier: 3},
+			{Number: 4, FuelConsumed: 2}, // stored without multiplier
+		}, 4)
+		assert.NoError(t, err)
+		assert.Equal(t, float32(8), prior.ConsumptionPerLap)
+	})
+
+	t.Run("No laps", func(t *testing.T) {
+		_, err := getFuelPriorFromStoredLaps([]StoredLap{}, 1)
+		assert.Error(t, err)
+	})
+}
+
+func Test_blendFuelConsumption(t *testing.T) {
+	prior := &FuelPrior{ConsumptionPerLap: 4, Source: PriorSourceUser}
+
+	t.Run("Nothing known", func(t *testing.T) {
+		_, err := blendFuelConsumption(nil, []float32{}, 0, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("Prior only", func(t *testing.T) {
+		estimate, err := blendFuelConsumption(prior, []float32{}, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, FuelEstimate{ConsumptionPerLap: 4, Confidence: 0, Source: PriorSourceUser}, estimate)
+	})
+
+	t.Run("Prior and half a lap", func(t *testing.T) {
+		// half a lap with 2.5 fuel is 5 per lap, weighted with 0.5 laps against 2 laps of prior
+		estimate, err := blendFuelConsumption(prior, []float32{}, 2.5, 0.5)
+		assert.NoError(t, err)
+		assert.Equal(t, float32(4.2), estimate.ConsumptionPerLap)
+		assert.InDelta(t, 0.1666, estimate.Confidence, 0.001)
+		assert.Equal(t, "user+live", estimate.Source)
+	})
+
+	t.Run("Too little progress in lap", func(t *testing.T) {
+		estimate, err := blendFuelConsumption(prior, []float32{}, 1, 0.05)
+		assert.NoError(t, err)
+		assert.Equal(t, float32(4), estimate.ConsumptionPerLap)
+	})
+
+	t.Run("Confidence grows with laps", func(t *testing.T) {
+		estimate, err := blendFuelConsumption(prior, []float32{5, 5, 5, 5}, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, float32(1), estimate.Confidence)
+		assert.InDelta(t, 4.666, estimate.ConsumptionPerLap, 0.001)
+	})
+
+	t.Run("Live only", func(t *testing.T) {
+		estimate, err := blendFuelConsumption(nil, []float32{5, 3}, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, float32(4), estimate.ConsumptionPerLap)
+		assert.Equal(t, "live", estimate.Source)
+	})
+}
+
+func TestStats_GetFuelConsumptionEstimate(t *testing.T) {
+	t.Run("From store before first lap", func(t *testing.T) {
+		s := NewStats()
+		store, err := NewStore(t.TempDir())
+		assert.NoError(t, err)
+		s.Store = store
+		s.Track = "Suzuka"
+		s.LastData.CarID = 1234
+
+		assert.NoError(t, store.AddLap(StoredLap{CarID: 1234, Track: "Suzuka", Number: 2, FuelConsumed: 3, Distance: 1000}))
+
+		estimate, err := s.GetFuelConsumptionEstimate()
+		assert.NoError(t, err)
+		assert.Equal(t, float32(3), estimate.ConsumptionPerLap)
+		assert.Equal(t, PriorSourceStore, estimate.Source)
+
+		// Drive half of the lap with 100 km/h and a consumption of 4 per lap
+		fuel := float32(50)
+		s.OngoingLap = Lap{FuelStart: fuel, Number: 1}
+		for i := int32(0); i <= 1125; i++ {
+			ld := gt7.GTData{PackageID: i, CarID: 1234, CarSpeed: 100, CurrentFuel: fuel - 2*float32(i)/1125}
+			s.LastData = &ld
+			s.History.Update(ld)
+			s.OngoingLap.DataHistory = append(s.OngoingLap.DataHistory, ld)
+		}
+
+		estimate, err = s.GetFuelConsumptionEstimate()
+		assert.NoError(t, err)
+		assert.InDelta(t, 3.2, estimate.ConsumptionPerLap, 0.01)
+		assert.Equal(t, "history+live", estimate.Source)
+	})
+
+	t.Run("Laps of the current session are no prior", func(t *testing.T) {
+		s := NewStats()
+		store, err := NewStore(t.TempDir())
+		assert.NoError(t, err)
+		s.Store = store
+		s.Track = "Suzuka"
+		s.LastData.CarID = 1234
+		s.Laps = []Lap{{Number: 1, FuelStart: 100, FuelEnd: 96, Duration: time.Minute}}
+
+		assert.NoError(t, store.AddLap(StoredLap{CarID: 1234, Track: "Suzuka", Number: 5, FuelConsumed: 3, Distance: 1000}))
+		assert.NoError(t, store.AddLap(newStoredLap(1234, "Suzuka", s.sessionID, s.Laps[0], s.Settings, time.Now())))
+
+		prior, err := s.GetFuelPrior()
+		assert.NoError(t, err)
+		assert.Equal(t, float32(3), prior.ConsumptionPerLap)
+
+		estimate, err := s.GetFuelConsumptionEstimate()
+		assert.NoError(t, err)
+		assert.Equal(t, "history+live", estimate.Source)
+		// the prior of 3 and the driven lap of 4 only count once
+		assert.InDelta(t, 3.33, estimate.ConsumptionPerLap, 0.01)
+	})
+
+	t.Run("User prior has precedence", func(t *testing.T) {
+		s := NewStats()
+		s.SetFuelPrior(2)
+		estimate, err := s.GetFuelConsumptionEstimate()
+		assert.NoError(t, err)
+		assert.Equal(t, float32(2), estimate.ConsumptionPerLap)
+		assert.Equal(t, PriorSourceUser, estimate.Source)
+	})
+}
@@ -1,7 +1,9 @@
 package experimental
 
 import (
+	"bytes"
 	"fmt"
+	"html/template"
 	"image"
 	_ "image/jpeg"
 	_ "image/png"
@@ -94,21 +96,20 @@ func (t TireData) Format() string {
 	return fmt.Sprintf("FL: %d, FR: %d, RL: %d, RR: %d", t.FrontLeft, t.FrontRight, t.RearLeft, t.RearRight)
 }
 
+var tireTableTemplate = template.Must(template.New("tiretable").Parse(
+	"<table class='tiretable'>" +
+		"<tr class='tirerow'><td>{{.FrontLeft}}</td><td>{{.FrontRight}}</td></tr>" +
+		"<tr class='tirerow'><td>{{.RearLeft}}</td><td>{{.RearRight}}</td></tr>" +
+		"</table>"))
+
 // Html gives a html table for the tires relative to their position
 func (t *TireData) Html() string {
-	return fmt.Sprintf(
-		"<table class='tiretable'>"+
-			"<tr class='tirerow'>"+
-			"<td>%d<td>"+
-			"<td>%d<td>"+
-			"</tr>"+
-			"<tr class='tirerow'>"+
-			"<td>%d<td>"+
-			"<td>%d<td>"+
-			"</tr>"+
-			"</table>"+
-			"", t.FrontLeft, t.FrontRight, t.RearLeft, t.RearRight,
-	)
+	buf := new(bytes.Buffer)
+	err := tireTableTemplate.Execute(buf, t)
+	if err != nil {
+		log.Printf("Error rendering tire table: %v", err)
+	}
+	return buf.String()
 }
 
 func (t *TireData) Diff(end TireData) TireData {
@@ -156,3 +156,11 @@ func Test_getRelativePositionForTires4k(t *testing.T) {
 	assert.Equal(t, 70, tireHeight)
 	assert.Equal(t, 22, tireWidth)
 }
+
+func TestTireData_Html(t *testing.T) {
+	tires := TireData{FrontLeft: 90, FrontRight: 85, RearLeft: 70, RearRight: 65}
+	assert.Equal(t, "<table class='tiretable'>"+
+		"<tr class='tirerow'><td>90</td><td>85</td></tr>"+
+		"<tr class='tirerow'><td>70</td><td>65</td></tr>"+
+		"</table>", tires.Html())
+}
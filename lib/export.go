@@ -0,0 +1,222 @@
+package lib
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const ExportCSV = "csv"
+const ExportJSONLines = "jsonl"
+
+var ExportFormats = []string{ExportCSV, ExportJSONLines}
+
+type telemetryChannel struct {
+	name  string
+	value func(data gt7.GTData) float32
+}
+
+// telemetryChannels can be exported besides time and distance, which are derived from the lap
+var telemetryChannels = []telemetryChannel{
+	{"package_id", func(d gt7.GTData) float32 { return float32(d.PackageID) }},
+	{"speed", func(d gt7.GTData) float32 { return float32(d.CarSpeed) }},
+	{"throttle", func(d gt7.GTData) float32 { return float32(d.Throttle) }},
+	{"brake", func(d gt7.GTData) float32 { return float32(d.Brake) }},
+	{"rpm", func(d gt7.GTData) float32 { return float32(d.RPM) }},
+	{"gear", func(d gt7.GTData) float32 { return float32(d.CurrentGear) }},
+	{"fuel", func(d gt7.GTData) float32 { return float32(d.CurrentFuel) }},
+	{"position_x", func(d gt7.GTData) float32 { return float32(d.PositionX) }},
+	{"position_y", func(d gt7.GTData) float32 { return float32(d.PositionY) }},
+	{"position_z", func(d gt7.GTData) float32 { return float32(d.PositionZ) }},
+	{"heading", func(d gt7.GTData) float32 { return float32(GetHeading(d)) }},
+	{"tire_temp_fl", func(d gt7.GTData) float32 { return float32(d.TyreTempFL) }},
+	{"tire_temp_fr", func(d gt7.GTData) float32 { return float32(d.TyreTempFR) }},
+	{"tire_temp_rl", func(d gt7.GTData) float32 { return float32(d.TyreTempRL) }},
+	{"tire_temp_rr", func(d gt7.GTData) float32 { return float32(d.TyreTempRR) }},
+	{"suspension_fl", func(d gt7.GTData) float32 { return float32(d.SuspensionFL) }},
+	{"suspension_fr", func(d gt7.GTData) float32 { return float32(d.SuspensionFR) }},
+	{"suspension_rl", func(d gt7.GTData) float32 { return float32(d.SuspensionRL) }},
+	{"suspension_rr", func(d gt7.GTData) float32 { return float32(d.SuspensionRR) }},
+	{"ride_height", func(d gt7.GTData) float32 { return float32(d.RideHeight) }},
+	{"oil_temp", func(d gt7.GTData) float32 { return float32(d.OilTemp) }},
+	{"water_temp", func(d gt7.GTData) float32 { return float32(d.WaterTemp) }},
+	{"oil_pressure", func(d gt7.GTData) float32 { return float32(d.OilPressure) }},
+}
+
+// TelemetryChannels are the names of the channels that can be exported
+var TelemetryChannels = func() []string {
+	names := []string{}
+	for _, c := range telemetryChannels {
+		names = append(names, c.name)
+	}
+	return names
+}()
+
+type LapSummary struct {
+	Lap int16 `json:"lap"`
+	// Duration and RaceTime are in seconds
+	Duration     float64 `json:"duration"`
+	RaceTime     float64 `json:"race_time"`
+	TopSpeed     float32 `json:"top_speed"`
+	FuelConsumed float32 `json:"fuel_consumed"`
+	// TireWear is the wear of the most worn tire in percent, -1 if unknown
+	TireWear  int  `json:"tire_wear"`
+	PitStops  int  `json:"pit_stops"`
+	Incidents int  `json:"incidents"`
+	Regular   bool `json:"regular"`
+}
+
+var lapSummaryColumns = []string{"lap", "duration", "race_time", "top_speed", "fuel_consumed", "tire_wear", "pit_stops", "incidents", "regular"}
+
+func (l LapSummary) values() []string {
+	return []string{
+		strconv.Itoa(int(l.Lap)),
+		strconv.FormatFloat(l.Duration, 'f', 3, 64),
+		strconv.FormatFloat(l.RaceTime, 'f', 3, 64),
+		strconv.FormatFloat(float64(l.TopSpeed), 'f', 1, 32),
+		strconv.FormatFloat(float64(l.FuelConsumed), 'f', 2, 32),
+		strconv.Itoa(l.TireWear),
+		strconv.Itoa(l.PitStops),
+		strconv.Itoa(l.Incidents),
+		strconv.FormatBool(l.Regular),
+	}
+}
+
+func GetLapSummary(lap Lap) LapSummary {
+	tireWear, _ := lap.GetTireWear()
+	return LapSummary{
+		Lap:          lap.Number,
+		Duration:     lap.Duration.Seconds(),
+		RaceTime:     lap.GetTotalRaceDurationAtEndOfLap().Seconds(),
+		TopSpeed:     lap.GetTopSpeed(),
+		FuelConsumed: lap.GetFuelConsumed(),
+		TireWear:     tireWear,
+		PitStops:     len(lap.PitStops),
+		Incidents:    len(lap.Incidents),
+		Regular:      lap.IsRegularLap(),
+	}
+}
+
+// ParseChannels parses channels separated by commas, an empty string selects all channels
+func ParseChannels(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return TelemetryChannels, nil
+	}
+	channels := []string{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if _, err := getTelemetryChannel(name); err != nil {
+			return nil, err
+		}
+		channels = append(channels, name)
+	}
+	return channels, nil
+}
+
+func getTelemetryChannel(name string) (telemetryChannel, error) {
+	for _, c := range telemetryChannels {
+		if c.name == name {
+			return c, nil
+		}
+	}
+	return telemetryChannel{}, fmt.Errorf("unknown channel %q, use some of %v", name, TelemetryChannels)
+}
+
+// rowWriter writes rows with the same columns as CSV or JSON Lines
+type rowWriter struct {
+	format  string
+	columns []string
+	csv     *csv.Writer
+	json    *json.Encoder
+}
+
+func newRowWriter(w io.Writer, format string, columns []string) (*rowWriter, error) {
+	switch format {
+	case ExportCSV:
+		r := &rowWriter{format: format, columns: columns, csv: csv.NewWriter(w)}
+		return r, r.csv.Write(columns)
+	case ExportJSONLines:
+		return &rowWriter{format: format, columns: columns, json: json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, use one of %v", format, ExportFormats)
+}
+
+// write writes the row, for JSON Lines v is written instead of the string values if it is not nil
+func (r *rowWriter) write(values []string, v interface{}) error {
+	if r.format == ExportCSV {
+		return r.csv.Write(values)
+	}
+	if v != nil {
+		return r.json.Encode(v)
+	}
+	row := map[string]json.Number{}
+	for i, column := range r.columns {
+		row[column] = json.Number(values[i])
+	}
+	return r.json.Encode(row)
+}
+
+func (r *rowWriter) flush() error {
+	if r.csv != nil {
+		r.csv.Flush()
+		return r.csv.Error()
+	}
+	return nil
+}
+
+// ExportLaps writes a summary of every lap in the format, one of ExportFormats
+func ExportLaps(w io.Writer, laps []Lap, format string) error {
+	rows, err := newRowWriter(w, format, lapSummaryColumns)
+	if err != nil {
+		return err
+	}
+	for _, lap := range laps {
+		summary := GetLapSummary(lap)
+		err = rows.write(summary.values(), summary)
+		if err != nil {
+			return fmt.Errorf("error exporting lap %d: %v", lap.Number, err)
+		}
+	}
+	return rows.flush()
+}
+
+// ExportTelemetry writes every nth package of the lap with the time and distance from the start
+// of the lap and the channels in the format, one of ExportFormats
+func ExportTelemetry(w io.Writer, lap Lap, channels []string, every int, format string) error {
+	if every < 1 {
+		return fmt.Errorf("every has to be at least 1, got %d", every)
+	}
+	selected := []telemetryChannel{}
+	for _, name := range channels {
+		c, err := getTelemetryChannel(name)
+		if err != nil {
+			return err
+		}
+		selected = append(selected, c)
+	}
+
+	rows, err := newRowWriter(w, format, append([]string{"time", "distance"}, channels...))
+	if err != nil {
+		return err
+	}
+	distances := lap.GetDistances()
+	for i := 0; i < len(lap.DataHistory); i += every {
+		data := lap.DataHistory[i]
+		values := []string{
+			strconv.FormatFloat(packageNumbersToDuration(data.PackageID-lap.DataHistory[0].PackageID).Seconds(), 'f', 3, 64),
+			strconv.FormatFloat(float64(distances[i]), 'f', 2, 32),
+		}
+		for _, c := range selected {
+			values = append(values, strconv.FormatFloat(float64(c.value(data)), 'f', -1, 32))
+		}
+		err = rows.write(values, nil)
+		if err != nil {
+			return fmt.Errorf("error exporting lap %d: %v", lap.Number, err)
+		}
+	}
+	return rows.flush()
+}
@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestParseChannels(t *testing.T) {
+	channels, err := ParseChannels("")
+	assert.NoError(t, err)
+	assert.Equal(t, TelemetryChannels, channels)
+
+	channels, err = ParseChannels("speed, brake")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"speed", "brake"}, channels)
+
+	_, err = ParseChannels("speed,warp")
+	assert.Error(t, err)
+}
+
+func TestExportLaps(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.NoError(t, ExportLaps(buf, getRaceLaps(), ExportCSV))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 6)
+	assert.Equal(t, "lap,duration,race_time,top_speed,fuel_consumed,tire_wear,pit_stops,incidents,regular", lines[0])
+	assert.Equal(t, "3,81.000,246.000,-1.0,5.00,-1,1,0,false", lines[3])
+
+	buf.Reset()
+	assert.NoError(t, ExportLaps(buf, getRaceLaps(), ExportJSONLines))
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 5)
+	summary := LapSummary{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &summary))
+	assert.Equal(t, LapSummary{Lap: 2, Duration: 80, RaceTime: 165, TopSpeed: -1, FuelConsumed: 5, TireWear: -1, Regular: true}, summary)
+
+	assert.Error(t, ExportLaps(buf, getRaceLaps(), "xlsx"))
+}
+
+func TestExportTelemetry(t *testing.T) {
+	lap := getLapOnStraight(180)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, ExportTelemetry(buf, lap, []string{"speed", "position_x"}, 100, ExportCSV))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 31)
+	assert.Equal(t, "time,distance,speed,position_x", lines[0])
+	// 100 packages at 180 km/h are 80m
+	assert.Equal(t, "1.600,80.00,180,-100", lines[2])
+
+	buf.Reset()
+	assert.NoError(t, ExportTelemetry(buf, lap, []string{"gear"}, 1000, ExportJSONLines))
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	row := map[string]float64{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &row))
+	assert.Equal(t, float64(16), row["time"])
+	assert.InDelta(t, 800, row["distance"], 0.1)
+	assert.Equal(t, float64(0), row["gear"])
+
+	assert.Error(t, ExportTelemetry(buf, lap, []string{"speed"}, 0, ExportCSV))
+	assert.Error(t, ExportTelemetry(buf, lap, []string{"warp"}, 1, ExportCSV))
+}
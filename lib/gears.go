@@ -0,0 +1,158 @@
+package lib
+
+import (
+	"fmt"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"time"
+)
+
+// shiftRPMTolerance is the difference in RPM to the best lap's upshifts that is not worth a feedback
+const shiftRPMTolerance = 200
+
+// limiterTolerance is the additional time on the limiter compared to the best lap that is not worth a feedback
+const limiterTolerance = 200 * time.Millisecond
+
+type CornerGear struct {
+	Corner int   `json:"corner"`
+	Gear   uint8 `json:"gear"`
+	// Start is the brake point of the corner, it is used to match the corner with other laps
+	Start float32 `json:"start"`
+}
+
+// ShiftAnalysis records the gear usage of a lap
+type ShiftAnalysis struct {
+	Lap               int16         `json:"lap"`
+	Upshifts          int           `json:"upshifts"`
+	AverageUpshiftRPM float32       `json:"average_upshift_rpm"`
+	TimeOnLimiter     time.Duration `json:"time_on_limiter"`
+	ApexGears         []CornerGear  `json:"apex_gears"`
+}
+
+type ShiftComparison struct {
+	Lap      ShiftAnalysis `json:"lap"`
+	Best     ShiftAnalysis `json:"best"`
+	Feedback []string      `json:"feedback"`
+}
+
+func isOnLimiter(data gt7.GTData) bool {
+	return data.RPMRevLimiter > 0 && data.RPM >= float32(data.RPMRevLimiter)
+}
+
+// GetShiftAnalysis finds the upshifts, the time on the limiter and the gear at the apex of every corner.
+// The apex is the slowest point between two braking zones
+func GetShiftAnalysis(lap Lap) ShiftAnalysis {
+	analysis := ShiftAnalysis{Lap: lap.Number, ApexGears: []CornerGear{}}
+
+	var upshiftRPM float32
+	for i, data := range lap.DataHistory {
+		if isOnLimiter(data) {
+			analysis.TimeOnLimiter += getSampleDuration(lap.DataHistory, i)
+		}
+		if i == 0 {
+			continue
+		}
+		before := lap.DataHistory[i-1]
+		// gear 0 is reverse, so shifting from it is no upshift
+		if before.CurrentGear > 0 && data.CurrentGear > before.CurrentGear {
+			analysis.Upshifts++
+			upshiftRPM += before.RPM
+		}
+	}
+	if analysis.Upshifts > 0 {
+		analysis.AverageUpshiftRPM = upshiftRPM / float32(analysis.Upshifts)
+	}
+
+	zones := GetBrakingZones(lap)
+	for z, zone := range zones {
+		end := len(lap.DataHistory)
+		if z+1 < len(zones) {
+			end = zones[z+1].startIndex
+		}
+		apex := zone.startIndex
+		for i := zone.startIndex; i < end; i++ {
+			if lap.DataHistory[i].CarSpeed < lap.DataHistory[apex].CarSpeed {
+				apex = i
+			}
+		}
+		analysis.ApexGears = append(analysis.ApexGears, CornerGear{
+			Corner: zone.Corner,
+			Gear:   lap.DataHistory[apex].CurrentGear,
+			Start:  zone.Start,
+		})
+	}
+	return analysis
+}
+
+// CompareShiftAnalysis gives feedback on the gear usage compared to the best lap
+func CompareShiftAnalysis(analysis ShiftAnalysis, best ShiftAnalysis) []string {
+	feedback := []string{}
+
+	if analysis.Upshifts > 0 && best.Upshifts > 0 {
+		rpmDelta := analysis.AverageUpshiftRPM - best.AverageUpshiftRPM
+		if rpmDelta < -shiftRPMTolerance {
+			feedback = append(feedback, fmt.Sprintf("upshifts %.0f rpm earlier than best", -rpmDelta))
+		} else if rpmDelta > shiftRPMTolerance {
+			feedback = append(feedback, fmt.Sprintf("upshifts %.0f rpm later than best", rpmDelta))
+		}
+	}
+
+	if analysis.TimeOnLimiter-best.TimeOnLimiter > limiterTolerance {
+		feedback = append(feedback, fmt.Sprintf("%.1fs more on the limiter than best", (analysis.TimeOnLimiter-best.TimeOnLimiter).Seconds()))
+	}
+
+	for _, apexGear := range analysis.ApexGears {
+		bestApexGear, err := matchApexGear(apexGear, best.ApexGears)
+		if err != nil {
+			continue
+		}
+		if apexGear.Gear != bestApexGear.Gear {
+			feedback = append(feedback, fmt.Sprintf("corner %d: gear %d at apex, best lap used %d", bestApexGear.Corner, apexGear.Gear, bestApexGear.Gear))
+		}
+	}
+	return feedback
+}
+
+// matchApexGear finds the corner of the reference lap by its brake point, like matchBrakingZone
+func matchApexGear(apexGear CornerGear, referenceGears []CornerGear) (CornerGear, error) {
+	zones := []BrakingZone{}
+	for _, reference := range referenceGears {
+		zones = append(zones, BrakingZone{Corner: reference.Corner, Start: reference.Start})
+	}
+	zone, err := matchBrakingZone(BrakingZone{Start: apexGear.Start}, zones)
+	if err != nil {
+		return CornerGear{}, err
+	}
+	return referenceGears[zone.Corner-1], nil
+}
+
+// GetShiftComparison compares the gear usage of the last lap with the one of the best lap
+func (s *Stats) GetShiftComparison() (ShiftComparison, error) {
+	if len(s.Laps) == 0 {
+		return ShiftComparison{Feedback: []string{}}, fmt.Errorf("no lap driven yet")
+	}
+	bestLap, err := s.GetBestLap()
+	if err != nil {
+		return ShiftComparison{Feedback: []string{}}, fmt.Errorf("error getting best lap: %v", err)
+	}
+	comparison := ShiftComparison{
+		Lap:  GetShiftAnalysis(s.Laps[len(s.Laps)-1]),
+		Best: GetShiftAnalysis(bestLap),
+	}
+	comparison.Feedback = CompareShiftAnalysis(comparison.Lap, comparison.Best)
+	return comparison, nil
+}
+
+// GetShiftLight returns 0 below the rev warning RPM rising to 1 at the rev limiter
+func GetShiftLight(data gt7.GTData) float32 {
+	if data.RPMRevLimiter <= data.RPMRevWarning {
+		return 0
+	}
+	light := (data.RPM - float32(data.RPMRevWarning)) / float32(data.RPMRevLimiter-data.RPMRevWarning)
+	if light < 0 {
+		return 0
+	}
+	if light > 1 {
+		return 1
+	}
+	return light
+}
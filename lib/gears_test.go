@@ -0,0 +1,108 @@
+package lib
+
+import (
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// getLapWithGears brakes at the given packages like getLapWithBrakingZones, drives through the corner in
+// apexGear and upshifts twice at shiftRPM after 150 and 160 packages. Before the first upshift the
+// engine is on the limiter for 10 packages
+func getLapWithGears(duration time.Duration, brakeAt []int32, apexGear uint8, shiftRPM float32) Lap {
+	lap := getLapWithBrakingZones(duration, brakeAt, 40)
+	for i := range lap.DataHistory {
+		data := &lap.DataHistory[i]
+		data.CurrentGear = apexGear + 2
+		data.RPM = 5000
+		data.RPMRevWarning = 7000
+		data.RPMRevLimiter = 8000
+		for _, start := range brakeAt {
+			p := int32(i) - start
+			switch {
+			case p < 0:
+			case p < 150:
+				data.CurrentGear = apexGear
+				if p >= 140 {
+					data.RPM = 8000
+				}
+			case p < 160:
+				data.CurrentGear = apexGear + 1
+			}
+			if p == 149 || p == 159 {
+				data.RPM = shiftRPM
+			}
+		}
+	}
+	return lap
+}
+
+func TestGetShiftAnalysis(t *testing.T) {
+	analysis := GetShiftAnalysis(getLapWithGears(time.Minute, []int32{500, 1500}, 2, 7500))
+	assert.Equal(t, int16(2), analysis.Lap)
+	assert.Equal(t, 4, analysis.Upshifts)
+	assert.Equal(t, float32(7500), analysis.AverageUpshiftRPM)
+	// the package before the upshift is below the limiter
+	assert.Equal(t, packageNumbersToDuration(18), analysis.TimeOnLimiter)
+	assert.Len(t, analysis.ApexGears, 2)
+	assert.Equal(t, uint8(2), analysis.ApexGears[0].Gear)
+	assert.Equal(t, 1, analysis.ApexGears[0].Corner)
+
+	// live only every sixth package is stored, the time on the limiter stays the same
+	analysis = GetShiftAnalysis(getLiveSamples(getLapWithGears(time.Minute, []int32{500, 1500}, 2, 7500), 6))
+	assert.InDelta(t, packageNumbersToDuration(18).Seconds(), analysis.TimeOnLimiter.Seconds(), 0.1)
+	assert.Len(t, analysis.ApexGears, 2)
+	assert.Equal(t, uint8(2), analysis.ApexGears[0].Gear)
+
+	analysis = GetShiftAnalysis(Lap{})
+	assert.Equal(t, 0, analysis.Upshifts)
+	assert.Len(t, analysis.ApexGears, 0)
+}
+
+func TestCompareShiftAnalysis(t *testing.T) {
+	best := GetShiftAnalysis(getLapWithGears(time.Minute, []int32{500, 1500}, 2, 7500))
+	assert.Equal(t, []string{}, CompareShiftAnalysis(best, best))
+
+	analysis := GetShiftAnalysis(getLapWithGears(time.Minute, []int32{500, 1500}, 3, 7000))
+	assert.Equal(t, []string{
+		"upshifts 500 rpm earlier than best",
+		"corner 1: gear 3 at apex, best lap used 2",
+		"corner 2: gear 3 at apex, best lap used 2",
+	}, CompareShiftAnalysis(analysis, best))
+}
+
+func TestStats_GetShiftComparison(t *testing.T) {
+	s := NewStats()
+	_, err := s.GetShiftComparison()
+	assert.Error(t, err)
+
+	s.Laps = []Lap{
+		getLapWithGears(time.Minute, []int32{500}, 2, 7500),
+		getLapWithGears(time.Minute+time.Second, []int32{500}, 3, 7500),
+	}
+	s.Laps[1].Number = 3
+	comparison, err := s.GetShiftComparison()
+	assert.NoError(t, err)
+	assert.Equal(t, int16(3), comparison.Lap.Lap)
+	assert.Equal(t, int16(2), comparison.Best.Lap)
+	assert.Equal(t, []string{"corner 1: gear 3 at apex, best lap used 2"}, comparison.Feedback)
+
+	// live only every sixth package is stored
+	for i := range s.Laps {
+		s.Laps[i] = getLiveSamples(s.Laps[i], 6)
+	}
+	comparison, err = s.GetShiftComparison()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"corner 1: gear 3 at apex, best lap used 2"}, comparison.Feedback)
+}
+
+func TestGetShiftLight(t *testing.T) {
+	data := gt7.GTData{RPM: 5000, RPMRevWarning: 7000, RPMRevLimiter: 8000}
+	assert.Equal(t, float32(0), GetShiftLight(data))
+	data.RPM = 7500
+	assert.Equal(t, float32(0.5), GetShiftLight(data))
+	data.RPM = 8200
+	assert.Equal(t, float32(1), GetShiftLight(data))
+	assert.Equal(t, float32(0), GetShiftLight(gt7.GTData{RPM: 5000}))
+}
@@ -6,6 +6,7 @@ import (
 	svg "github.com/ajstarks/svgo"
 	gt7 "github.com/snipem/go-gt7-telemetry/lib"
 	"math"
+	"time"
 )
 
 func DrawLapToSVG(lap Lap) string {
@@ -41,29 +42,15 @@ func DrawLapToSVG(lap Lap) string {
 		heightOfTrack,
 	)
 
-	path := ""
-
-	for i, _ := range lap.DataHistory {
-
-		if i > detail && i%detail == 0 {
-			x1 := int(lap.DataHistory[i-detail].PositionX)
-			y1 := int(lap.DataHistory[i-detail].PositionZ)
-			x2 := int(lap.DataHistory[i].PositionX)
-			y2 := int(lap.DataHistory[i].PositionZ)
-
-			path += fmt.Sprintf("M %d,%d L %d,%d ", x1, y1, x2, y2)
-		}
-	}
-
 	if len(lap.DataHistory) > 0 {
-		// Close gap
-		path += fmt.Sprintf("M %d,%d L %d,%d z", int(lap.DataHistory[len(lap.DataHistory)-1].PositionX), int(lap.DataHistory[len(lap.DataHistory)-1].PositionZ), int(lap.DataHistory[0].PositionX), int(lap.DataHistory[0].PositionZ))
-
+		path := getLapPath(lap, detail)
 		// https://www.w3.org/TR/SVG11/paths.html
 		canvas.Path(path, "fill:none;stroke:black;stroke-width:14")
 		canvas.Path(path, "fill:none;stroke:white;stroke-width:10")
 	}
 
+	drawSlipEvents(canvas, lap)
+
 	canvas.End()
 
 	svgoutput := buf.String()
@@ -74,6 +61,37 @@ func DrawLapToSVG(lap Lap) string {
 
 }
 
+func drawSlipEvents(canvas *svg.SVG, lap Lap) {
+	for _, event := range GetSlipEvents(lap) {
+		color := "orange"
+		if event.Type == SlipLockUp {
+			color = "red"
+		}
+		canvas.Circle(int(event.PositionX), int(event.PositionZ), 8, "fill:"+color, `class="slip-event"`)
+	}
+}
+
+// getLapPath returns the closed path of the lap with a segment every detail packages
+func getLapPath(lap Lap, detail int) string {
+	path := ""
+
+	for i, _ := range lap.DataHistory {
+
+		if i > detail && i%detail == 0 {
+			x1 := int(lap.DataHistory[i-detail].PositionX)
+			y1 := int(lap.DataHistory[i-detail].PositionZ)
+			x2 := int(lap.DataHistory[i].PositionX)
+			y2 := int(lap.DataHistory[i].PositionZ)
+
+			path += fmt.Sprintf("M %d,%d L %d,%d ", x1, y1, x2, y2)
+		}
+	}
+
+	// Close gap
+	path += fmt.Sprintf("M %d,%d L %d,%d z", int(lap.DataHistory[len(lap.DataHistory)-1].PositionX), int(lap.DataHistory[len(lap.DataHistory)-1].PositionZ), int(lap.DataHistory[0].PositionX), int(lap.DataHistory[0].PositionZ))
+	return path
+}
+
 func getMaxMinValuesForCoordinates(history []gt7.GTData) (float64, float64, float64, float64) {
 
 	if len(history) == 0 {
@@ -104,3 +122,91 @@ func getMaxMinValuesForCoordinates(history []gt7.GTData) (float64, float64, floa
 	}
 	return float64(maxx), float64(maxz), float64(minx), float64(minz)
 }
+
+// DrawRideHeightBySpeedSVG plots the average ride height over the speed
+func DrawRideHeightBySpeedSVG(rideHeights []RideHeightAtSpeed) string {
+	width, height, padding := 400, 200, 30
+
+	maxSpeed, maxRideHeight := float32(1), float32(1)
+	for _, r := range rideHeights {
+		if r.Speed+rideHeightSpeedBinWidth > maxSpeed {
+			maxSpeed = r.Speed + rideHeightSpeedBinWidth
+		}
+		if r.RideHeight > maxRideHeight {
+			maxRideHeight = r.RideHeight
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	canvas := svg.New(buf)
+	canvas.Start(width+2*padding, height+2*padding)
+	canvas.Line(padding, padding+height, padding+width, padding+height, "stroke:black")
+	canvas.Line(padding, padding, padding, padding+height, "stroke:black")
+	canvas.Text(padding+width/2, 2*padding+height-5, "km/h", "text-anchor:middle;font-size:12px")
+	canvas.Text(5, padding-10, fmt.Sprintf("ride height, max %.0f mm", maxRideHeight), "font-size:12px")
+
+	xs, ys := []int{}, []int{}
+	for _, r := range rideHeights {
+		// points are drawn in the middle of the speed bin
+		xs = append(xs, padding+int((r.Speed+rideHeightSpeedBinWidth/2)/maxSpeed*float32(width)))
+		ys = append(ys, padding+height-int(r.RideHeight/maxRideHeight*float32(height)))
+	}
+	if len(xs) > 0 {
+		canvas.Polyline(xs, ys, "fill:none;stroke:blue;stroke-width:2")
+	}
+	canvas.End()
+	return buf.String()
+}
+
+// DrawLapTimesSVG plots the lap times over the laps, laps into the pit are blue and laps with incidents red
+func DrawLapTimesSVG(laps []Lap) string {
+	width, height, padding := 600, 200, 30
+
+	minTime, maxTime := time.Duration(0), time.Duration(0)
+	for _, lap := range laps {
+		if minTime == 0 || lap.Duration < minTime {
+			minTime = lap.Duration
+		}
+		if lap.Duration > maxTime {
+			maxTime = lap.Duration
+		}
+	}
+	timeRange := (maxTime - minTime).Seconds()
+	if timeRange <= 0 {
+		timeRange = 1
+	}
+
+	buf := new(bytes.Buffer)
+	canvas := svg.New(buf)
+	canvas.Start(width+2*padding, height+2*padding)
+	canvas.Line(padding, padding+height, padding+width, padding+height, "stroke:black")
+	canvas.Line(padding, padding, padding, padding+height, "stroke:black")
+	canvas.Text(5, padding-10, fmt.Sprintf("lap time %s - %s", GetSportFormat(minTime), GetSportFormat(maxTime)), "font-size:12px")
+	canvas.Text(padding+width/2, 2*padding+height-5, "lap", "text-anchor:middle;font-size:12px")
+
+	xs, ys := []int{}, []int{}
+	for i, lap := range laps {
+		x := padding + width/2
+		if len(laps) > 1 {
+			x = padding + i*width/(len(laps)-1)
+		}
+		y := padding + height - int((lap.Duration-minTime).Seconds()/timeRange*float64(height))
+		xs, ys = append(xs, x), append(ys, y)
+	}
+	if len(xs) > 0 {
+		canvas.Polyline(xs, ys, "fill:none;stroke:grey;stroke-width:1")
+	}
+	for i, lap := range laps {
+		color := "black"
+		if lap.IsLapIntoPit() {
+			color = "blue"
+		}
+		if lap.IsIncidentLap() {
+			color = "red"
+		}
+		canvas.Circle(xs[i], ys[i], 4, "fill:"+color)
+		canvas.Text(xs[i], padding+height+15, fmt.Sprintf("%d", lap.Number), "text-anchor:middle;font-size:10px")
+	}
+	canvas.End()
+	return buf.String()
+}
@@ -52,3 +52,20 @@ func TestDrawLapEmpty(t *testing.T) {
 	}
 	f.WriteString(svg)
 }
+
+func TestDrawRideHeightBySpeedSVG(t *testing.T) {
+	svg := DrawRideHeightBySpeedSVG([]RideHeightAtSpeed{{Speed: 0, RideHeight: 80}, {Speed: 20, RideHeight: 70}})
+	assert.Contains(t, svg, "<svg")
+	assert.Contains(t, svg, "polyline")
+
+	assert.NotContains(t, DrawRideHeightBySpeedSVG([]RideHeightAtSpeed{}), "polyline")
+}
+
+func TestDrawLapTimesSVG(t *testing.T) {
+	svg := DrawLapTimesSVG(getRaceLaps())
+	assert.Contains(t, svg, "polyline")
+	assert.Contains(t, svg, "fill:blue")
+	assert.Contains(t, svg, "fill:red")
+
+	assert.Contains(t, DrawLapTimesSVG([]Lap{}), "<svg")
+}
@@ -0,0 +1,202 @@
+package lib
+
+import (
+	"fmt"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"strings"
+)
+
+// healthLearningLaps is the number of regular laps the normal operating ranges are learned from,
+// the first lap is left out since the engine is still warming up
+const healthLearningLaps = 3
+
+// healthMargin widens the learned ranges by this share so that small variations do not raise warnings
+const healthMargin = 0.05
+
+// healthSmoothing is the weight of a new package for the smoothed values, this makes warnings
+// follow trends and not single spikes
+const healthSmoothing = 0.02
+
+type HealthRange struct {
+	Min float32 `json:"min"`
+	Avg float32 `json:"avg"`
+	Max float32 `json:"max"`
+}
+
+type LapHealth struct {
+	Lap         int16       `json:"lap"`
+	OilTemp     HealthRange `json:"oil_temp"`
+	WaterTemp   HealthRange `json:"water_temp"`
+	OilPressure HealthRange `json:"oil_pressure"`
+}
+
+type HealthReport struct {
+	Laps []LapHealth `json:"laps"`
+	// Normal are the learned operating ranges, only valid if Learned is set
+	Normal   LapHealth `json:"normal"`
+	Learned  bool      `json:"learned"`
+	Warnings []string  `json:"warnings"`
+}
+
+// healthMonitor smooths the health values of every package and keeps the learned ranges
+type healthMonitor struct {
+	oilTemp     float32
+	waterTemp   float32
+	oilPressure float32
+	initialized bool
+	normal      LapHealth
+	// learnedFromLaps is 0 as long as there is no regular lap to learn from
+	learnedFromLaps int
+}
+
+func (h LapHealth) String() string {
+	return fmt.Sprintf("%.0f°C / %.0f°C / %.1f", h.OilTemp.Max, h.WaterTemp.Max, h.OilPressure.Min)
+}
+
+func getHealthRange(values []float32) HealthRange {
+	if len(values) == 0 {
+		return HealthRange{}
+	}
+	r := HealthRange{Min: values[0], Max: values[0]}
+	var sum float32
+	for _, v := range values {
+		if v < r.Min {
+			r.Min = v
+		}
+		if v > r.Max {
+			r.Max = v
+		}
+		sum += v
+	}
+	r.Avg = sum / float32(len(values))
+	return r
+}
+
+// GetLapHealth returns the range of oil temperature, water temperature and oil pressure of the lap
+func GetLapHealth(lap Lap) LapHealth {
+	oilTemp, waterTemp, oilPressure := []float32{}, []float32{}, []float32{}
+	for _, data := range lap.DataHistory {
+		oilTemp = append(oilTemp, data.OilTemp)
+		waterTemp = append(waterTemp, data.WaterTemp)
+		oilPressure = append(oilPressure, data.OilPressure)
+	}
+	return LapHealth{
+		Lap:         lap.Number,
+		OilTemp:     getHealthRange(oilTemp),
+		WaterTemp:   getHealthRange(waterTemp),
+		OilPressure: getHealthRange(oilPressure),
+	}
+}
+
+// mergeHealthRange returns a range covering both ranges
+func mergeHealthRange(r HealthRange, other HealthRange) HealthRange {
+	if other.Min < r.Min {
+		r.Min = other.Min
+	}
+	if other.Max > r.Max {
+		r.Max = other.Max
+	}
+	r.Avg = (r.Avg + other.Avg) / 2
+	return r
+}
+
+func widenHealthRange(r HealthRange) HealthRange {
+	margin := r.Avg * healthMargin
+	return HealthRange{Min: r.Min - margin, Avg: r.Avg, Max: r.Max + margin}
+}
+
+// learnNormalHealthRanges learns the normal operating ranges from the first regular laps,
+// it returns the number of laps learned from
+func learnNormalHealthRanges(laps []Lap) (LapHealth, int, error) {
+	normal := LapHealth{}
+	n := 0
+	for _, lap := range laps {
+		if !lap.IsRegularLap() || len(lap.DataHistory) == 0 {
+			continue
+		}
+		health := GetLapHealth(lap)
+		if n == 0 {
+			normal = health
+		} else {
+			normal.OilTemp = mergeHealthRange(normal.OilTemp, health.OilTemp)
+			normal.WaterTemp = mergeHealthRange(normal.WaterTemp, health.WaterTemp)
+			normal.OilPressure = mergeHealthRange(normal.OilPressure, health.OilPressure)
+		}
+		n++
+		if n == healthLearningLaps {
+			break
+		}
+	}
+	if n == 0 {
+		return LapHealth{}, 0, fmt.Errorf("no regular laps to learn normal operating ranges from")
+	}
+	normal.Lap = 0
+	normal.OilTemp = widenHealthRange(normal.OilTemp)
+	normal.WaterTemp = widenHealthRange(normal.WaterTemp)
+	normal.OilPressure = widenHealthRange(normal.OilPressure)
+	return normal, n, nil
+}
+
+// learnHealthRanges is called after every finished lap until the normal ranges are learned from enough laps
+func (s *Stats) learnHealthRanges() {
+	m := &s.healthMonitor
+	if m.learnedFromLaps >= healthLearningLaps {
+		return
+	}
+	normal, n, err := learnNormalHealthRanges(s.Laps)
+	if err != nil {
+		return
+	}
+	m.normal = normal
+	m.learnedFromLaps = n
+}
+
+// monitorHealth is called for every package and smooths the health values
+func (s *Stats) monitorHealth(ld *gt7.GTData) {
+	m := &s.healthMonitor
+	if !m.initialized {
+		m.oilTemp, m.waterTemp, m.oilPressure = ld.OilTemp, ld.WaterTemp, ld.OilPressure
+		m.initialized = true
+		return
+	}
+	m.oilTemp += healthSmoothing * (ld.OilTemp - m.oilTemp)
+	m.waterTemp += healthSmoothing * (ld.WaterTemp - m.waterTemp)
+	m.oilPressure += healthSmoothing * (ld.OilPressure - m.oilPressure)
+}
+
+// GetHealthWarnings returns a warning for every value that is outside its normal range
+func (s *Stats) GetHealthWarnings() []string {
+	m := s.healthMonitor
+	warnings := []string{}
+	if m.learnedFromLaps == 0 || !m.initialized {
+		return warnings
+	}
+	if m.oilTemp > m.normal.OilTemp.Max {
+		warnings = append(warnings, fmt.Sprintf("oil temperature %.0f°C above normal %.0f-%.0f°C", m.oilTemp, m.normal.OilTemp.Min, m.normal.OilTemp.Max))
+	}
+	if m.waterTemp > m.normal.WaterTemp.Max {
+		warnings = append(warnings, fmt.Sprintf("water temperature %.0f°C above normal %.0f-%.0f°C", m.waterTemp, m.normal.WaterTemp.Min, m.normal.WaterTemp.Max))
+	}
+	if m.oilPressure < m.normal.OilPressure.Min {
+		warnings = append(warnings, fmt.Sprintf("oil pressure %.1f below normal %.1f-%.1f", m.oilPressure, m.normal.OilPressure.Min, m.normal.OilPressure.Max))
+	}
+	return warnings
+}
+
+func (s *Stats) GetHealthWarning() string {
+	return strings.Join(s.GetHealthWarnings(), ", ")
+}
+
+// GetHealthReport returns the health of every lap together with the normal ranges
+func (s *Stats) GetHealthReport() HealthReport {
+	report := HealthReport{
+		Laps:     []LapHealth{},
+		Normal:   s.healthMonitor.normal,
+		Learned:  s.healthMonitor.learnedFromLaps > 0,
+		Warnings: s.GetHealthWarnings(),
+	}
+	for _, lap := range s.Laps {
+		report.Laps = append(report.Laps, GetLapHealth(lap))
+	}
+	return report
+}
@@ -0,0 +1,80 @@
+package lib
+
+import (
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func getLapWithHealth(number int16, oilTemp float32, waterTemp float32, oilPressure float32) Lap {
+	lap := Lap{Number: number, Duration: time.Minute, FuelStart: 100, FuelEnd: 90}
+	for i := 0; i < 100; i++ {
+		// values swing by 1 around the given values
+		swing := float32(i%3 - 1)
+		lap.DataHistory = append(lap.DataHistory, gt7.GTData{
+			OilTemp:     oilTemp + swing,
+			WaterTemp:   waterTemp + swing,
+			OilPressure: oilPressure + swing/10,
+		})
+	}
+	return lap
+}
+
+func TestGetLapHealth(t *testing.T) {
+	health := GetLapHealth(getLapWithHealth(2, 110, 90, 5))
+	assert.Equal(t, int16(2), health.Lap)
+	assert.Equal(t, float32(109), health.OilTemp.Min)
+	assert.Equal(t, float32(111), health.OilTemp.Max)
+	assert.InDelta(t, 110, health.OilTemp.Avg, 0.1)
+	assert.InDelta(t, 4.9, health.OilPressure.Min, 0.001)
+	assert.Equal(t, "111°C / 91°C / 4.9", health.String())
+
+	assert.Equal(t, LapHealth{}, GetLapHealth(Lap{}))
+}
+
+func Test_learnNormalHealthRanges(t *testing.T) {
+	_, _, err := learnNormalHealthRanges([]Lap{getLapWithHealth(1, 80, 70, 5)})
+	assert.Error(t, err, "the warm-up lap is not learned from")
+
+	normal, n, err := learnNormalHealthRanges([]Lap{
+		getLapWithHealth(1, 80, 70, 5),
+		getLapWithHealth(2, 110, 90, 5),
+		getLapWithHealth(3, 112, 90, 5),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	// 109 to 113 widened by 5% of the average
+	assert.InDelta(t, 109-5.55, normal.OilTemp.Min, 0.01)
+	assert.InDelta(t, 113+5.55, normal.OilTemp.Max, 0.01)
+}
+
+func TestStats_GetHealthWarnings(t *testing.T) {
+	s := NewStats()
+	s.Laps = []Lap{getLapWithHealth(1, 80, 70, 5), getLapWithHealth(2, 110, 90, 5)}
+
+	data := gt7.GTData{OilTemp: 140, WaterTemp: 90, OilPressure: 3}
+	s.monitorHealth(&data)
+	assert.Equal(t, []string{}, s.GetHealthWarnings(), "ranges are not learned yet")
+
+	s.learnHealthRanges()
+	assert.Equal(t, []string{
+		"oil temperature 140°C above normal 104-116°C",
+		"oil pressure 3.0 below normal 4.7-5.3",
+	}, s.GetHealthWarnings())
+
+	t.Run("Single spikes are smoothed", func(t *testing.T) {
+		s := NewStats()
+		s.Laps = []Lap{getLapWithHealth(1, 80, 70, 5), getLapWithHealth(2, 110, 90, 5)}
+		s.learnHealthRanges()
+		normal := gt7.GTData{OilTemp: 110, WaterTemp: 90, OilPressure: 5}
+		s.monitorHealth(&normal)
+		spike := gt7.GTData{OilTemp: 200, WaterTemp: 90, OilPressure: 5}
+		s.monitorHealth(&spike)
+		assert.Equal(t, "", s.GetHealthWarning())
+
+		report := s.GetHealthReport()
+		assert.True(t, report.Learned)
+		assert.Len(t, report.Laps, 2)
+	})
+}
@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	svg "github.com/ajstarks/svgo"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+)
+
+const HeatmapSpeed = "speed"
+const HeatmapThrottle = "throttle"
+const HeatmapBrake = "brake"
+const HeatmapGear = "gear"
+
+var HeatmapChannels = []string{HeatmapSpeed, HeatmapThrottle, HeatmapBrake, HeatmapGear}
+
+// gearColors are distinct colors for the gears, starting with reverse
+var gearColors = []string{"grey", "purple", "blue", "teal", "green", "yellowgreen", "orange", "orangered", "red", "darkred"}
+
+type heatmapChannel struct {
+	unit  string
+	value func(data gt7.GTData) float32
+}
+
+var heatmapChannels = map[string]heatmapChannel{
+	HeatmapSpeed:    {unit: "km/h", value: func(data gt7.GTData) float32 { return data.CarSpeed }},
+	HeatmapThrottle: {unit: "%", value: func(data gt7.GTData) float32 { return data.Throttle }},
+	HeatmapBrake:    {unit: "%", value: func(data gt7.GTData) float32 { return data.Brake }},
+	HeatmapGear:     {unit: "", value: func(data gt7.GTData) float32 { return float32(data.CurrentGear) }},
+}
+
+func getGearColor(gear int) string {
+	if gear < 0 || gear >= len(gearColors) {
+		return "black"
+	}
+	return gearColors[gear]
+}
+
+// drawHeatmapLegend draws a color bar from the minimum to the maximum value, for gears every gear gets a box
+func drawHeatmapLegend(canvas *svg.SVG, channel string, x int, y int, width int, height int, min float32, max float32) {
+	fontSize := height / 2
+	textStyle := fmt.Sprintf("font-size:%dpx;text-anchor:middle", fontSize)
+
+	if channel == HeatmapGear {
+		gears := int(max-min) + 1
+		for gear := int(min); gear <= int(max); gear++ {
+			boxX := x + (gear-int(min))*width/gears
+			canvas.Rect(boxX, y, width/gears, height, "fill:"+getGearColor(gear))
+			canvas.Text(boxX+width/gears/2, y+height+fontSize, fmt.Sprintf("%d", gear), textStyle)
+		}
+		return
+	}
+
+	canvas.Def()
+	canvas.LinearGradient("heatmap-legend", 0, 0, 100, 0, []svg.Offcolor{
+		{Offset: 0, Color: getHeatColor(min, min, max), Opacity: 1},
+		{Offset: 100, Color: getHeatColor(max, min, max), Opacity: 1},
+	})
+	canvas.DefEnd()
+	canvas.Rect(x, y, width, height, "fill:url(#heatmap-legend)")
+	canvas.Text(x, y+height+fontSize, fmt.Sprintf("%.0f %s", min, heatmapChannels[channel].unit), textStyle)
+	canvas.Text(x+width, y+height+fontSize, fmt.Sprintf("%.0f %s", max, heatmapChannels[channel].unit), textStyle)
+}
+
+// DrawLapHeatmapSVG draws the track map with every segment colored by the value of the channel,
+// one of HeatmapChannels, and a legend below the track
+func DrawLapHeatmapSVG(lap Lap, channel string) (string, error) {
+	c, ok := heatmapChannels[channel]
+	if !ok {
+		return "", fmt.Errorf("unknown channel %q, use one of %v", channel, HeatmapChannels)
+	}
+	if len(lap.DataHistory) == 0 {
+		return "", fmt.Errorf("no data in lap %d", lap.Number)
+	}
+
+	min, max := c.value(lap.DataHistory[0]), c.value(lap.DataHistory[0])
+	for _, data := range lap.DataHistory {
+		if c.value(data) < min {
+			min = c.value(data)
+		}
+		if c.value(data) > max {
+			max = c.value(data)
+		}
+	}
+
+	maxx, maxz, minx, minz := getBounds(lap.DataHistory)
+	width := int(maxx-minx) + 2*mapPadding
+	height := int(maxz-minz) + 2*mapPadding
+	// the legend scales with the track, the coordinates are in meters
+	legendHeight := width / 20
+	if legendHeight < 10 {
+		legendHeight = 10
+	}
+	viewHeight := height + 3*legendHeight
+
+	buf := new(bytes.Buffer)
+	canvas := svg.New(buf)
+	canvas.Startview(width, viewHeight, int(minx)-mapPadding, int(minz)-mapPadding, width, viewHeight)
+
+	drawColoredLine(canvas, lap, 8, func(from int, to int) string {
+		if channel == HeatmapGear {
+			return getGearColor(int(c.value(lap.DataHistory[to])))
+		}
+		return getHeatColor(c.value(lap.DataHistory[to]), min, max)
+	})
+	drawHeatmapLegend(canvas, channel,
+		int(minx)-mapPadding+width/4, int(minz)-mapPadding+height,
+		width/2, legendHeight, min, max)
+
+	canvas.End()
+	return buf.String(), nil
+}
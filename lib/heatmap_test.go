@@ -0,0 +1,36 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDrawLapHeatmapSVG(t *testing.T) {
+	lap := getLapOnStraight(180)
+	for i := range lap.DataHistory {
+		lap.DataHistory[i].Throttle = float32(i % 101)
+		lap.DataHistory[i].CurrentGear = uint8(1 + i/1000)
+	}
+
+	svg, err := DrawLapHeatmapSVG(lap, HeatmapThrottle)
+	assert.NoError(t, err)
+	assert.Contains(t, svg, "<svg")
+	assert.Contains(t, svg, "url(#heatmap-legend)")
+	assert.Contains(t, svg, "0 %")
+	assert.Contains(t, svg, "100 %")
+	assert.Contains(t, svg, "stroke:rgb(0,0,255)")
+
+	svg, err = DrawLapHeatmapSVG(lap, HeatmapGear)
+	assert.NoError(t, err)
+	assert.NotContains(t, svg, "heatmap-legend")
+	for _, color := range []string{"purple", "blue", "teal"} {
+		assert.Contains(t, svg, "stroke:"+color)
+		assert.Contains(t, svg, "fill:"+color)
+	}
+	assert.NotContains(t, svg, "stroke:green")
+
+	_, err = DrawLapHeatmapSVG(lap, "rpm")
+	assert.Error(t, err)
+	_, err = DrawLapHeatmapSVG(Lap{}, HeatmapSpeed)
+	assert.Error(t, err)
+}
@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"fmt"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"math"
+	"time"
+)
+
+const IncidentCollision = "collision"
+const IncidentSpin = "spin"
+const IncidentDeceleration = "sudden deceleration"
+const IncidentOffTrack = "off track"
+
+// suddenDeceleration is the deceleration in km/h per second that is too strong for braking, about 5.6g
+const suddenDeceleration = 200
+
+// decelerationWindow is the minimum number of packages the deceleration is measured over to filter noise
+const decelerationWindow = 6
+
+// spinYawRate is the yaw rate in rad/s that is too high for cornering
+const spinYawRate = 2
+
+// collisionAcceleration is the change of velocity in m/s per second between two samples that only an impact
+// causes, about 12.7g
+const collisionAcceleration = 125
+
+// offTrackDistance is the distance in meters to the racing line that counts as off track
+const offTrackDistance = 12
+
+// racingLineSearchDistance is how far in meters along the lap the racing line is searched for the nearest point,
+// the distance differs between laps because of different lines
+const racingLineSearchDistance = 150
+
+type Incident struct {
+	Type string `json:"type"`
+	// Start is the distance from the start of the lap in meters
+	Start     float32       `json:"start"`
+	Duration  time.Duration `json:"duration"`
+	PositionX float32       `json:"position_x"`
+	PositionZ float32       `json:"position_z"`
+}
+
+func formatIncidents(incidents []Incident) []string {
+	formatted := []string{}
+	for _, incident := range incidents {
+		formatted = append(formatted, fmt.Sprintf("%s at %.0fm", incident.Type, incident.Start))
+	}
+	return formatted
+}
+
+// getDistancesToRacingLine returns for every package of the lap the distance to the nearest point of the racing line
+func getDistancesToRacingLine(lap Lap, racingLine Lap) []float32 {
+	distances := lap.GetDistances()
+	lineDistances := racingLine.GetDistances()
+
+	toLine := make([]float32, len(lap.DataHistory))
+	first := 0
+	for i, data := range lap.DataHistory {
+		for first < len(lineDistances) && lineDistances[first] < distances[i]-racingLineSearchDistance {
+			first++
+		}
+		nearest := math.MaxFloat64
+		for j := first; j < len(lineDistances) && lineDistances[j] <= distances[i]+racingLineSearchDistance; j++ {
+			line := racingLine.DataHistory[j]
+			d := math.Hypot(float64(data.PositionX-line.PositionX), float64(data.PositionZ-line.PositionZ))
+			if d < nearest {
+				nearest = d
+			}
+		}
+		toLine[i] = float32(nearest)
+	}
+	return toLine
+}
+
+func getVelocityJump(data gt7.GTData, before gt7.GTData) float64 {
+	return math.Sqrt(math.Pow(float64(data.VelocityX-before.VelocityX), 2) +
+		math.Pow(float64(data.VelocityY-before.VelocityY), 2) +
+		math.Pow(float64(data.VelocityZ-before.VelocityZ), 2))
+}
+
+// getIncidentType returns the most severe incident at the package or an empty string
+func getIncidentType(history []gt7.GTData, i int, distanceToRacingLine float32) string {
+	data := history[i]
+	if i > 0 && getVelocityJump(data, history[i-1])/getSampleDuration(history, i).Seconds() > collisionAcceleration {
+		return IncidentCollision
+	}
+	if math.Abs(float64(data.AngularVelocityY)) > spinYawRate {
+		return IncidentSpin
+	}
+	// live only about every sixth package is stored, so the window is found by the package IDs
+	for j := i - 1; j >= 0 && history[j].PackageID < data.PackageID; j-- {
+		packages := data.PackageID - history[j].PackageID
+		if packages < decelerationWindow {
+			continue
+		}
+		deceleration := (history[j].CarSpeed - data.CarSpeed) / float32(packageNumbersToDuration(packages).Seconds())
+		if deceleration > suddenDeceleration {
+			return IncidentDeceleration
+		}
+		break
+	}
+	if distanceToRacingLine > offTrackDistance {
+		return IncidentOffTrack
+	}
+	return ""
+}
+
+// DetectIncidents finds collisions, spins, sudden decelerations and excursions off the racing line.
+// The racing line is taken from the reference lap, without data of it off track excursions are not detected
+func DetectIncidents(lap Lap, reference Lap) []Incident {
+	incidents := []Incident{}
+	distances := lap.GetDistances()
+
+	toRacingLine := make([]float32, len(lap.DataHistory))
+	if len(reference.DataHistory) > 0 {
+		toRacingLine = getDistancesToRacingLine(lap, reference)
+	}
+
+	var incident *Incident
+	for i, data := range lap.DataHistory {
+		incidentType := getIncidentType(lap.DataHistory, i, toRacingLine[i])
+		if incident != nil && incident.Type == incidentType {
+			incident.Duration += getSampleDuration(lap.DataHistory, i)
+			continue
+		}
+		if incident != nil {
+			incidents = append(incidents, *incident)
+			incident = nil
+		}
+		if incidentType != "" {
+			incident = &Incident{
+				Type:      incidentType,
+				Start:     distances[i],
+				Duration:  getSampleDuration(lap.DataHistory, i),
+				PositionX: data.PositionX,
+				PositionZ: data.PositionZ,
+			}
+		}
+	}
+	if incident != nil {
+		incidents = append(incidents, *incident)
+	}
+	return incidents
+}
+
+func (l Lap) IsIncidentLap() bool {
+	return len(l.Incidents) > 0
+}
+
+// getLapsForAverages returns the laps averages and deviations are calculated from,
+// incident laps are left out unless they should be included
+func (s *Stats) getLapsForAverages() []Lap {
+	if s.IncludeIncidentLaps {
+		return s.Laps
+	}
+	laps := []Lap{}
+	for _, lap := range s.Laps {
+		if !lap.IsIncidentLap() {
+			laps = append(laps, lap)
+		}
+	}
+	return laps
+}
@@ -0,0 +1,139 @@
+package lib
+
+import (
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// getStraightLap drives 1000 packages with 180 km/h along the x axis, z is the offset to the racing line
+func getStraightLap(z func(i int) float32) Lap {
+	lap := Lap{Number: 2, Duration: time.Minute}
+	for i := 0; i < 1000; i++ {
+		lap.DataHistory = append(lap.DataHistory, gt7.GTData{
+			PackageID: int32(i),
+			CarSpeed:  180,
+			VelocityX: 50,
+			PositionX: float32(i) * 0.8,
+			PositionZ: z(i),
+		})
+	}
+	return lap
+}
+
+func Test_getIncidentType(t *testing.T) {
+	history := getStraightLap(func(i int) float32 { return 0 }).DataHistory
+	assert.Equal(t, "", getIncidentType(history, 500, 0))
+	assert.Equal(t, IncidentOffTrack, getIncidentType(history, 500, 20))
+
+	history[500].AngularVelocityY = -3
+	assert.Equal(t, IncidentSpin, getIncidentType(history, 500, 20))
+
+	history[500].VelocityX = 45
+	assert.Equal(t, IncidentCollision, getIncidentType(history, 500, 20))
+
+	// 30 km/h in 6 packages are more than 300 km/h per second
+	history[600].CarSpeed = 150
+	assert.Equal(t, IncidentDeceleration, getIncidentType(history, 600, 0))
+}
+
+func Test_getIncidentTypeLive(t *testing.T) {
+	// live only every sixth package is stored
+	history := getLiveSamples(getStraightLap(func(i int) float32 { return 0 }), 6).DataHistory
+	for i := range history {
+		history[i].CarSpeed = 180
+	}
+
+	brake := func(g float32) {
+		for i := 80; i < len(history); i++ {
+			// g in km/h per second over the 6 packages between the samples
+			speed := history[i-1].CarSpeed - g*9.81*3.6*float32(packageNumbersToDuration(6).Seconds())
+			history[i].CarSpeed = speed
+			history[i].VelocityX = speed / 3.6
+		}
+	}
+	brake(0.9)
+	assert.Equal(t, "", getIncidentType(history, 90, 0))
+	brake(2)
+	assert.Equal(t, "", getIncidentType(history, 90, 0), "hard braking is no incident")
+
+	// 100 km/h in 6 packages are about 1000 km/h per second
+	history[100].CarSpeed = history[99].CarSpeed - 100
+	assert.Equal(t, IncidentDeceleration, getIncidentType(history, 100, 0))
+
+	// 20 m/s in 6 packages are about 21g
+	history[110].VelocityX = history[109].VelocityX - 20
+	assert.Equal(t, IncidentCollision, getIncidentType(history, 110, 0))
+}
+
+func TestDetectIncidents(t *testing.T) {
+	racingLine := getStraightLap(func(i int) float32 { return 0 })
+
+	lap := getStraightLap(func(i int) float32 {
+		if i >= 400 && i < 450 {
+			return 30
+		}
+		return 0
+	})
+	incidents := DetectIncidents(lap, racingLine)
+	assert.Len(t, incidents, 1)
+	assert.Equal(t, IncidentOffTrack, incidents[0].Type)
+	assert.Equal(t, packageNumbersToDuration(50), incidents[0].Duration)
+	assert.InDelta(t, 320, incidents[0].Start, 1)
+	assert.Equal(t, []string{"off track at 320m"}, formatIncidents(incidents))
+
+	assert.Len(t, DetectIncidents(lap, Lap{}), 0, "no racing line to detect off track excursions")
+
+	// live only every sixth package is stored
+	incidents = DetectIncidents(getLiveSamples(lap, 6), racingLine)
+	assert.Len(t, incidents, 1)
+	assert.Equal(t, packageNumbersToDuration(48), incidents[0].Duration)
+	assert.Len(t, DetectIncidents(racingLine, racingLine), 0)
+}
+
+func TestStats_getLapsForAverages(t *testing.T) {
+	s := NewStats()
+	s.Laps = []Lap{
+		{Number: 2, Duration: time.Minute, FuelStart: 100, FuelEnd: 90},
+		{Number: 3, Duration: 2 * time.Minute, FuelStart: 90, FuelEnd: 70, Incidents: []Incident{{Type: IncidentSpin}}},
+		{Number: 4, Duration: time.Minute, FuelStart: 70, FuelEnd: 60},
+	}
+
+	assert.Len(t, s.getLapsForAverages(), 2)
+	average, err := s.GetAverageFuelConsumptionPerLap()
+	assert.NoError(t, err)
+	assert.Equal(t, float32(10), average)
+	deviation, err := s.GetLapTimeDeviation()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), deviation)
+
+	s.IncludeIncidentLaps = true
+	assert.Len(t, s.getLapsForAverages(), 3)
+	deviation, err = s.GetLapTimeDeviation()
+	assert.NoError(t, err)
+	assert.NotEqual(t, time.Duration(0), deviation)
+
+	table, err := getHtmlTableForLaps(s.Laps, []SpeedTrap{}, LapTableColumns, LanguageEnglish)
+	assert.NoError(t, err)
+	assert.Contains(t, table, "<tr class='incident'>")
+}
+
+func TestStats_GetBestLapWithoutIncidentLaps(t *testing.T) {
+	s := NewStats()
+	data := []gt7.GTData{{}}
+	s.Laps = []Lap{
+		{Number: 2, Duration: 90 * time.Second, DataHistory: data},
+		// cutting the track is often the fastest lap
+		{Number: 3, Duration: 80 * time.Second, DataHistory: data, Incidents: []Incident{{Type: IncidentOffTrack}}},
+	}
+
+	bestLap, err := s.GetBestLap()
+	assert.NoError(t, err)
+	assert.Equal(t, int16(2), bestLap.Number)
+
+	s.IncludeIncidentLaps = true
+	bestLap, err = s.GetBestLap()
+	assert.NoError(t, err)
+	assert.Equal(t, int16(3), bestLap.Number)
+}
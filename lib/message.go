@@ -7,33 +7,57 @@ type CarPosition struct {
 }
 
 type RealTimeMessage struct {
-	Speed                      string      `json:"speed"`
-	PackageID                  int32       `json:"package_id"`
-	FuelLeft                   string      `json:"fuel_left"`
-	FuelConsumptionLastLap     string      `json:"fuel_consumption_last_lap"`
-	TimeSinceStart             string      `json:"time_since_start"`
-	FuelNeededToFinishRace     int32       `json:"fuel_needed_to_finish_race"`
-	FuelConsumptionAvg         string      `json:"fuel_consumption_avg"`
-	FuelDiv                    string      `json:"fuel_div"`
-	RaceTimeInMinutes          int32       `json:"race_time_in_minutes"`
-	ValidState                 bool        `json:"valid_state"`
-	LapsLeftInRace             int16       `json:"laps_left_in_race"`
-	EndOfRaceType              string      `json:"end_of_race_type"`
-	FuelConsumptionPerMinute   string      `json:"fuel_consumption_per_minute"`
-	LowestTireTemp             float32     `json:"lowest_tire_temp"`
-	ErrorMessage               string      `json:"error_message"`
-	NextPitStop                int16       `json:"next_pit_stop"`
-	CurrentLapProgressAdjusted string      `json:"current_lap_progress_adjusted"`
-	Tires                      string      `json:"tires"`
-	LapTimeDeviation           string      `json:"lap_time_deviation"`
-	TireTemperatures           []int       `json:"tire_temperatures"`
-	TCSActive                  bool        `json:"tcs_active"`
-	ASMActive                  bool        `json:"asma_active"`
-	RisingTrailbreaking        bool        `json:"rising_trailbreaking"`
-	Position                   CarPosition `json:"position"`
+	Speed                           string      `json:"speed"`
+	PackageID                       int32       `json:"package_id"`
+	FuelLeft                        string      `json:"fuel_left"`
+	FuelConsumptionLastLap          string      `json:"fuel_consumption_last_lap"`
+	TimeSinceStart                  string      `json:"time_since_start"`
+	FuelNeededToFinishRace          int32       `json:"fuel_needed_to_finish_race"`
+	FuelConsumptionAvg              string      `json:"fuel_consumption_avg"`
+	FuelConsumptionAvgMethod        string      `json:"fuel_consumption_avg_method"`
+	FuelConsumptionAvgSpread        string      `json:"fuel_consumption_avg_spread"`
+	FuelDiv                         string      `json:"fuel_div"`
+	RaceTimeInMinutes               int32       `json:"race_time_in_minutes"`
+	ValidState                      bool        `json:"valid_state"`
+	LapsLeftInRace                  int16       `json:"laps_left_in_race"`
+	EndOfRaceType                   string      `json:"end_of_race_type"`
+	FuelConsumptionPerMinute        string      `json:"fuel_consumption_per_minute"`
+	LowestTireTemp                  float32     `json:"lowest_tire_temp"`
+	ErrorMessage                    string      `json:"error_message"`
+	NextPitStop                     int16       `json:"next_pit_stop"`
+	CurrentLapProgressAdjusted      string      `json:"current_lap_progress_adjusted"`
+	Tires                           string      `json:"tires"`
+	LapTimeDeviation                string      `json:"lap_time_deviation"`
+	TireTemperatures                []int       `json:"tire_temperatures"`
+	TCSActive                       bool        `json:"tcs_active"`
+	SlipAlert                       string      `json:"slip_alert"`
+	ShiftLight                      float32     `json:"shift_light"`
+	HealthWarning                   string      `json:"health_warning"`
+	ColdTireWarning                 string      `json:"cold_tire_warning"`
+	ASMActive                       bool        `json:"asma_active"`
+	RisingTrailbreaking             bool        `json:"rising_trailbreaking"`
+	Position                        CarPosition `json:"position"`
+	FuelConsumptionInstant          string      `json:"fuel_consumption_instant"`
+	FuelConsumptionInstantPerMinute string      `json:"fuel_consumption_instant_per_minute"`
+	FuelConsumptionCurrentLap       string      `json:"fuel_consumption_current_lap"`
+	FuelConsumptionEstimate         string      `json:"fuel_consumption_estimate"`
+	FuelEstimateConfidence          float32     `json:"fuel_estimate_confidence"`
+	FuelEstimateSource              string      `json:"fuel_estimate_source"`
+	PitStopsNeeded                  int16       `json:"pit_stops_needed"`
+	StrategyLimitedBy               string      `json:"strategy_limited_by"`
+	PitLoss                         string      `json:"pit_loss"`
+	TotalPitLoss                    string      `json:"total_pit_loss"`
+	MultiplierWarning               string      `json:"multiplier_warning"`
 }
 
 type HeavyMessage struct {
-	FormattedLaps string `json:"formatted_laps"`
-	LapSVG        string `json:"lap_svg"`
+	FormattedLaps    string             `json:"formatted_laps"`
+	LapSVG           string             `json:"lap_svg"`
+	BrakingFeedback  []CornerFeedback   `json:"braking_feedback"`
+	Traction         TractionAnalysis   `json:"traction"`
+	SlipEvents       []CornerSlipCount  `json:"slip_events"`
+	ShiftFeedback    []string           `json:"shift_feedback"`
+	TireTemperatures []TireTemperatures `json:"tire_temperatures"`
+	Consistency      ConsistencyReport  `json:"consistency"`
+	Traces           map[string]string  `json:"traces"`
 }
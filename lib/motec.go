@@ -0,0 +1,426 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/montanaflynn/stats"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// ExportMoTeC exports the whole session as MoTeC .ld log
+const ExportMoTeC = "motec"
+
+// MoTeCFrequency is the rate in Hz GT7 sends packages at, the highest sample rate of a MoTeC log
+const MoTeCFrequency = 60
+
+// the magic numbers of the MoTeC .ld format are taken from logs written by MoTeC devices
+const ldMarker = 0x40
+const ldChannelCounter = 0x2ee1
+
+// ldTypeFloat is the data type of the channels written, 32 bit floats
+const ldTypeFloat = 0x07
+
+type ldHeader struct {
+	Marker         uint32
+	_              [4]byte
+	ChannelMetaPtr uint32
+	ChannelDataPtr uint32
+	_              [20]byte
+	EventPtr       uint32
+	_              [24]byte
+	Unknown1       uint16
+	Unknown2       uint16
+	Unknown3       uint16
+	DeviceSerial   uint32
+	DeviceType     [8]byte
+	DeviceVersion  uint16
+	Unknown4       uint16
+	NumChannels    uint32
+	_              [4]byte
+	Date           [16]byte
+	_              [16]byte
+	Time           [16]byte
+	_              [16]byte
+	Driver         [64]byte
+	VehicleID      [64]byte
+	_              [64]byte
+	Venue          [64]byte
+	_              [64]byte
+	_              [1024]byte
+	ProLogging     uint32
+	_              [66]byte
+	ShortComment   [64]byte
+	_              [126]byte
+}
+
+type ldEvent struct {
+	Name     [64]byte
+	Session  [64]byte
+	Comment  [1024]byte
+	VenuePtr uint16
+}
+
+type ldVenue struct {
+	Name       [64]byte
+	_          [1034]byte
+	VehiclePtr uint16
+}
+
+type ldVehicle struct {
+	ID      [64]byte
+	_       [128]byte
+	Weight  uint32
+	Type    [32]byte
+	Comment [32]byte
+}
+
+type ldChannel struct {
+	PrevPtr   uint32
+	NextPtr   uint32
+	DataPtr   uint32
+	DataLen   uint32
+	Counter   uint16
+	DataTypeA uint16
+	DataType  uint16
+	Frequency uint16
+	Shift     int16
+	Mul       int16
+	Scale     int16
+	Dec       int16
+	Name      [32]byte
+	ShortName [8]byte
+	Unit      [12]byte
+	_         [40]byte
+}
+
+type MoTeCInfo struct {
+	Driver  string
+	Vehicle string
+	Venue   string
+	Event   string
+	Session string
+	Comment string
+	Time    time.Time
+}
+
+type MoTeCChannel struct {
+	Name      string
+	ShortName string
+	Unit      string
+	Frequency int
+	Data      []float32
+}
+
+type MoTeCLog struct {
+	Info     MoTeCInfo
+	Channels []MoTeCChannel
+}
+
+type motecChannel struct {
+	name      string
+	shortName string
+	unit      string
+	value     func(d gt7.GTData) float32
+}
+
+// motecChannels are named like the channels of MoTeC loggers, so the default workbooks of i2 find them
+var motecChannels = []motecChannel{
+	{"Lap Number", "Lap", "", func(d gt7.GTData) float32 { return float32(d.CurrentLap) }},
+	{"Ground Speed", "Speed", "km/h", func(d gt7.GTData) float32 { return d.CarSpeed }},
+	{"Throttle Pos", "Thr", "%", func(d gt7.GTData) float32 { return d.Throttle }},
+	{"Brake Pos", "Brk", "%", func(d gt7.GTData) float32 { return d.Brake }},
+	{"Engine RPM", "RPM", "rpm", func(d gt7.GTData) float32 { return d.RPM }},
+	{"Gear", "Gear", "", func(d gt7.GTData) float32 { return float32(d.CurrentGear) }},
+	{"Fuel Level", "Fuel", "%", func(d gt7.GTData) float32 { return d.CurrentFuel }},
+	{"Car Pos X", "PosX", "m", func(d gt7.GTData) float32 { return d.PositionX }},
+	{"Car Pos Y", "PosY", "m", func(d gt7.GTData) float32 { return d.PositionY }},
+	{"Car Pos Z", "PosZ", "m", func(d gt7.GTData) float32 { return d.PositionZ }},
+	{"Heading", "Head", "deg", GetHeading},
+	{"Tyre Temp FL", "TTFL", "C", func(d gt7.GTData) float32 { return d.TyreTempFL }},
+	{"Tyre Temp FR", "TTFR", "C", func(d gt7.GTData) float32 { return d.TyreTempFR }},
+	{"Tyre Temp RL", "TTRL", "C", func(d gt7.GTData) float32 { return d.TyreTempRL }},
+	{"Tyre Temp RR", "TTRR", "C", func(d gt7.GTData) float32 { return d.TyreTempRR }},
+	{"Susp Pos FL", "SusFL", "mm", func(d gt7.GTData) float32 { return d.SuspensionFL * 1000 }},
+	{"Susp Pos FR", "SusFR", "mm", func(d gt7.GTData) float32 { return d.SuspensionFR * 1000 }},
+	{"Susp Pos RL", "SusRL", "mm", func(d gt7.GTData) float32 { return d.SuspensionRL * 1000 }},
+	{"Susp Pos RR", "SusRR", "mm", func(d gt7.GTData) float32 { return d.SuspensionRR * 1000 }},
+	{"Ride Height", "RideH", "mm", func(d gt7.GTData) float32 { return d.RideHeight }},
+	{"Engine Oil Temp", "OilT", "C", func(d gt7.GTData) float32 { return d.OilTemp }},
+	{"Engine Water Temp", "WatT", "C", func(d gt7.GTData) float32 { return d.WaterTemp }},
+	{"Engine Oil Pressure", "OilP", "bar", func(d gt7.GTData) float32 { return d.OilPressure }},
+}
+
+func toFixed(dst []byte, s string) {
+	copy(dst, s)
+}
+
+func fromFixed(b []byte) string {
+	return strings.TrimRight(string(bytes.TrimRight(b, "\x00")), " ")
+}
+
+// GetSessionData returns the telemetry of all laps and the ongoing lap
+func (s *Stats) GetSessionData() []gt7.GTData {
+	data := []gt7.GTData{}
+	for _, lap := range s.Laps {
+		data = append(data, lap.DataHistory...)
+	}
+	return append(data, s.OngoingLap.DataHistory...)
+}
+
+// getSampleSpacing returns the number of packages between two samples. It is the median gap of the
+// package IDs, live only about every sixth package is stored, rounded down to a divisor of
+// MoTeCFrequency so that the sample rate is a whole number
+func getSampleSpacing(data []gt7.GTData) int32 {
+	gaps := stats.Float64Data{}
+	for i := 1; i < len(data); i++ {
+		if gap := data[i].PackageID - data[i-1].PackageID; gap > 0 {
+			gaps = append(gaps, float64(gap))
+		}
+	}
+	median, err := gaps.Median()
+	if err != nil {
+		return 1
+	}
+	spacing := int32(1)
+	for s := int32(1); s <= MoTeCFrequency && float64(s) <= median; s++ {
+		if MoTeCFrequency%s == 0 {
+			spacing = s
+		}
+	}
+	return spacing
+}
+
+// fillDroppedPackages returns a sample every spacing packages, which is the last package at or before
+// it, so that dropped samples keep the constant rate MoTeC expects. Longer gaps like pauses are not filled
+func fillDroppedPackages(data []gt7.GTData, spacing int32) []gt7.GTData {
+	filled := []gt7.GTData{data[0]}
+	next := data[0].PackageID + spacing
+	for i := 1; i < len(data); i++ {
+		gap := data[i].PackageID - data[i-1].PackageID
+		if gap <= 0 || gap > MoTeCFrequency {
+			filled = append(filled, data[i])
+			next = data[i].PackageID + spacing
+			continue
+		}
+		for ; next < data[i].PackageID; next += spacing {
+			filled = append(filled, data[i-1])
+		}
+		if next == data[i].PackageID {
+			filled = append(filled, data[i])
+			next += spacing
+		}
+	}
+	return filled
+}
+
+// WriteMoTeC writes the telemetry of a session as MoTeC .ld log. Every package is a sample at
+// MoTeCFrequency, telemetry with only every few packages is written at the lower rate it has
+func WriteMoTeC(w io.Writer, data []gt7.GTData, info MoTeCInfo) error {
+	if len(data) == 0 {
+		return fmt.Errorf("no telemetry to export")
+	}
+	spacing := getSampleSpacing(data)
+	data = fillDroppedPackages(data, spacing)
+
+	eventPtr := binary.Size(ldHeader{})
+	venuePtr := eventPtr + binary.Size(ldEvent{})
+	vehiclePtr := venuePtr + binary.Size(ldVenue{})
+	metaPtr := vehiclePtr + binary.Size(ldVehicle{})
+	metaSize := binary.Size(ldChannel{})
+	dataPtr := metaPtr + len(motecChannels)*metaSize
+	// every sample is a float32
+	dataSize := len(data) * 4
+
+	header := ldHeader{
+		Marker:         ldMarker,
+		ChannelMetaPtr: uint32(metaPtr),
+		ChannelDataPtr: uint32(dataPtr),
+		EventPtr:       uint32(eventPtr),
+		Unknown1:       1,
+		Unknown2:       0x4240,
+		Unknown3:       0xf,
+		DeviceSerial:   0x1f44,
+		DeviceVersion:  420,
+		Unknown4:       0xadb0,
+		NumChannels:    uint32(len(motecChannels)),
+		ProLogging:     0xc81a4,
+	}
+	toFixed(header.DeviceType[:], "ADL")
+	toFixed(header.Date[:], info.Time.Format("02/01/2006"))
+	toFixed(header.Time[:], info.Time.Format("15:04:05"))
+	toFixed(header.Driver[:], info.Driver)
+	toFixed(header.VehicleID[:], info.Vehicle)
+	toFixed(header.Venue[:], info.Venue)
+	toFixed(header.ShortComment[:], info.Comment)
+
+	event := ldEvent{VenuePtr: uint16(venuePtr)}
+	toFixed(event.Name[:], info.Event)
+	toFixed(event.Session[:], info.Session)
+	toFixed(event.Comment[:], info.Comment)
+	venue := ldVenue{VehiclePtr: uint16(vehiclePtr)}
+	toFixed(venue.Name[:], info.Venue)
+	vehicle := ldVehicle{}
+	toFixed(vehicle.ID[:], info.Vehicle)
+
+	buf := new(bytes.Buffer)
+	for _, v := range []interface{}{header, event, venue, vehicle} {
+		err := binary.Write(buf, binary.LittleEndian, v)
+		if err != nil {
+			return fmt.Errorf("error writing MoTeC header: %v", err)
+		}
+	}
+
+	for i, c := range motecChannels {
+		channel := ldChannel{
+			DataPtr:   uint32(dataPtr + i*dataSize),
+			DataLen:   uint32(len(data)),
+			Counter:   uint16(ldChannelCounter + i),
+			DataTypeA: ldTypeFloat,
+			DataType:  4,
+			Frequency: uint16(MoTeCFrequency / spacing),
+			Mul:       1,
+			Scale:     1,
+		}
+		if i > 0 {
+			channel.PrevPtr = uint32(metaPtr + (i-1)*metaSize)
+		}
+		if i < len(motecChannels)-1 {
+			channel.NextPtr = uint32(metaPtr + (i+1)*metaSize)
+		}
+		toFixed(channel.Name[:], c.name)
+		toFixed(channel.ShortName[:], c.shortName)
+		toFixed(channel.Unit[:], c.unit)
+		err := binary.Write(buf, binary.LittleEndian, channel)
+		if err != nil {
+			return fmt.Errorf("error writing MoTeC channel %s: %v", c.name, err)
+		}
+	}
+
+	for _, c := range motecChannels {
+		samples := make([]float32, len(data))
+		for i := range data {
+			samples[i] = c.value(data[i])
+		}
+		err := binary.Write(buf, binary.LittleEndian, samples)
+		if err != nil {
+			return fmt.Errorf("error writing MoTeC channel %s: %v", c.name, err)
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func readAt(b []byte, ptr uint32, v interface{}) error {
+	if int(ptr)+binary.Size(v) > len(b) {
+		return fmt.Errorf("pointer %d outside of the log with %d bytes", ptr, len(b))
+	}
+	return binary.Read(bytes.NewReader(b[ptr:]), binary.LittleEndian, v)
+}
+
+// readChannelData decodes the samples of the channel, integers are scaled like MoTeC does
+func readChannelData(b []byte, channel ldChannel) ([]float32, error) {
+	samples := make([]float32, channel.DataLen)
+	switch {
+	case channel.DataTypeA == ldTypeFloat && channel.DataType == 4:
+		err := readAt(b, channel.DataPtr, samples)
+		return samples, err
+	case channel.DataType == 2 || channel.DataType == 4:
+		raw := make([]float64, channel.DataLen)
+		if channel.DataType == 2 {
+			ints := make([]int16, channel.DataLen)
+			if err := readAt(b, channel.DataPtr, ints); err != nil {
+				return nil, err
+			}
+			for i, v := range ints {
+				raw[i] = float64(v)
+			}
+		} else {
+			ints := make([]int32, channel.DataLen)
+			if err := readAt(b, channel.DataPtr, ints); err != nil {
+				return nil, err
+			}
+			for i, v := range ints {
+				raw[i] = float64(v)
+			}
+		}
+		scale := float64(channel.Scale)
+		if scale == 0 {
+			scale = 1
+		}
+		for i, v := range raw {
+			samples[i] = float32((v/scale*math.Pow(10, -float64(channel.Dec)) + float64(channel.Shift)) * float64(channel.Mul))
+		}
+		return samples, nil
+	}
+	return nil, fmt.Errorf("unsupported data type %d/%d", channel.DataTypeA, channel.DataType)
+}
+
+// ReadMoTeC parses a MoTeC .ld log
+func ReadMoTeC(r io.Reader) (MoTeCLog, error) {
+	log := MoTeCLog{}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return log, fmt.Errorf("error reading MoTeC log: %v", err)
+	}
+
+	header := ldHeader{}
+	if err := readAt(b, 0, &header); err != nil {
+		return log, fmt.Errorf("error reading MoTeC header: %v", err)
+	}
+	if header.Marker != ldMarker {
+		return log, fmt.Errorf("not a MoTeC log, marker is %#x", header.Marker)
+	}
+	log.Info.Driver = fromFixed(header.Driver[:])
+	log.Info.Vehicle = fromFixed(header.VehicleID[:])
+	log.Info.Venue = fromFixed(header.Venue[:])
+	log.Info.Comment = fromFixed(header.ShortComment[:])
+	log.Info.Time, _ = time.Parse("02/01/2006 15:04:05", fromFixed(header.Date[:])+" "+fromFixed(header.Time[:]))
+
+	if header.EventPtr > 0 {
+		event := ldEvent{}
+		if err := readAt(b, header.EventPtr, &event); err != nil {
+			return log, fmt.Errorf("error reading MoTeC event: %v", err)
+		}
+		log.Info.Event = fromFixed(event.Name[:])
+		log.Info.Session = fromFixed(event.Session[:])
+	}
+
+	for ptr := header.ChannelMetaPtr; ptr != 0; {
+		channel := ldChannel{}
+		if err := readAt(b, ptr, &channel); err != nil {
+			return log, fmt.Errorf("error reading MoTeC channel: %v", err)
+		}
+		samples, err := readChannelData(b, channel)
+		if err != nil {
+			return log, fmt.Errorf("error reading data of MoTeC channel %s: %v", fromFixed(channel.Name[:]), err)
+		}
+		log.Channels = append(log.Channels, MoTeCChannel{
+			Name:      fromFixed(channel.Name[:]),
+			ShortName: fromFixed(channel.ShortName[:]),
+			Unit:      fromFixed(channel.Unit[:]),
+			Frequency: int(channel.Frequency),
+			Data:      samples,
+		})
+		if len(log.Channels) > int(header.NumChannels) {
+			return log, fmt.Errorf("more channels than the %d in the header", header.NumChannels)
+		}
+		ptr = channel.NextPtr
+	}
+	return log, nil
+}
+
+// GetChannel returns the channel with the name
+func (l MoTeCLog) GetChannel(name string) (MoTeCChannel, error) {
+	for _, c := range l.Channels {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return MoTeCChannel{}, fmt.Errorf("no channel %s in the log", name)
+}
@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"bytes"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func getMoTeCData() []gt7.GTData {
+	data := []gt7.GTData{}
+	for i := 0; i < 120; i++ {
+		data = append(data, gt7.GTData{
+			PackageID:    int32(1000 + i),
+			CurrentLap:   int16(1 + i/60),
+			CarSpeed:     float32(100 + i),
+			Throttle:     float32(i % 100),
+			RPM:          6000,
+			CurrentGear:  4,
+			SuspensionFL: 0.05,
+			PositionX:    float32(-i),
+		})
+	}
+	return data
+}
+
+func TestWriteMoTeC(t *testing.T) {
+	info := MoTeCInfo{
+		Driver:  "snipem",
+		Vehicle: "Car 1234",
+		Venue:   "Spa",
+		Event:   "race",
+		Session: "Race",
+		Time:    time.Date(2024, 5, 3, 20, 15, 0, 0, time.UTC),
+	}
+	buf := new(bytes.Buffer)
+	assert.NoError(t, WriteMoTeC(buf, getMoTeCData(), info))
+
+	log, err := ReadMoTeC(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, info, log.Info)
+	assert.Len(t, log.Channels, len(motecChannels))
+
+	speed, err := log.GetChannel("Ground Speed")
+	assert.NoError(t, err)
+	assert.Equal(t, "km/h", speed.Unit)
+	assert.Equal(t, MoTeCFrequency, speed.Frequency)
+	assert.Len(t, speed.Data, 120)
+	assert.Equal(t, float32(100), speed.Data[0])
+	assert.Equal(t, float32(219), speed.Data[119])
+
+	lapNumber, err := log.GetChannel("Lap Number")
+	assert.NoError(t, err)
+	assert.Equal(t, float32(1), lapNumber.Data[59])
+	assert.Equal(t, float32(2), lapNumber.Data[60])
+
+	suspension, err := log.GetChannel("Susp Pos FL")
+	assert.NoError(t, err)
+	assert.Equal(t, "mm", suspension.Unit)
+	assert.InDelta(t, 50, suspension.Data[0], 0.001)
+
+	_, err = log.GetChannel("Warp Speed")
+	assert.Error(t, err)
+}
+
+func TestWriteMoTeCFillsDroppedPackages(t *testing.T) {
+	data := getMoTeCData()
+	// drop two packages
+	data = append(data[:10], data[12:]...)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, WriteMoTeC(buf, data, MoTeCInfo{}))
+	log, err := ReadMoTeC(buf)
+	assert.NoError(t, err)
+
+	speed, err := log.GetChannel("Ground Speed")
+	assert.NoError(t, err)
+	assert.Len(t, speed.Data, 120)
+	assert.Equal(t, []float32{109, 109, 109, 112}, speed.Data[9:13])
+}
+
+func TestWriteMoTeCLiveSamples(t *testing.T) {
+	// live only every sixth package is stored, one of the samples is dropped
+	data := getLiveSamples(Lap{DataHistory: getMoTeCData()}, 6).DataHistory
+	data = append(data[:10], data[11:]...)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, WriteMoTeC(buf, data, MoTeCInfo{}))
+	log, err := ReadMoTeC(buf)
+	assert.NoError(t, err)
+
+	speed, err := log.GetChannel("Ground Speed")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, speed.Frequency)
+	assert.Len(t, speed.Data, 20)
+	assert.Equal(t, []float32{154, 154, 166}, speed.Data[9:12])
+}
+
+func Test_getSampleSpacing(t *testing.T) {
+	assert.Equal(t, int32(1), getSampleSpacing(getMoTeCData()))
+	assert.Equal(t, int32(6), getSampleSpacing(getLiveSamples(Lap{DataHistory: getMoTeCData()}, 6).DataHistory))
+	// 7 packages are no whole sample rate
+	assert.Equal(t, int32(6), getSampleSpacing(getLiveSamples(Lap{DataHistory: getMoTeCData()}, 7).DataHistory))
+	assert.Equal(t, int32(1), getSampleSpacing(getMoTeCData()[:1]))
+}
+
+func TestWriteMoTeCWithoutData(t *testing.T) {
+	assert.Error(t, WriteMoTeC(new(bytes.Buffer), []gt7.GTData{}, MoTeCInfo{}))
+}
+
+func TestReadMoTeCInvalid(t *testing.T) {
+	_, err := ReadMoTeC(bytes.NewBufferString("not a log"))
+	assert.Error(t, err)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, WriteMoTeC(buf, getMoTeCData(), MoTeCInfo{}))
+	_, err = ReadMoTeC(bytes.NewReader(buf.Bytes()[:buf.Len()-10]))
+	assert.Error(t, err)
+}
+
+func TestGetSessionData(t *testing.T) {
+	s := NewStats()
+	s.Laps = []Lap{{DataHistory: getMoTeCData()[:60]}, {DataHistory: getMoTeCData()[60:100]}}
+	s.OngoingLap = Lap{DataHistory: getMoTeCData()[100:]}
+	assert.Equal(t, getMoTeCData(), s.GetSessionData())
+}
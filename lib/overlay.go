@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	svg "github.com/ajstarks/svgo"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"math"
+)
+
+const OverlayColorSpeed = "speed"
+const OverlayColorDelta = "delta"
+
+// overlayDetail is the number of packages per drawn segment, higher is less detail
+const overlayDetail = 5
+
+// mapPadding is the space in meters around the drawn lines of a map
+const mapPadding = 30
+
+// cornerZoomDistance is the distance in meters after the brake point that is shown when zooming into a corner
+const cornerZoomDistance = 300
+
+// maxDeltaPerSegment is the time in seconds gained or lost per segment that gets the full color
+const maxDeltaPerSegment = 0.02
+
+type LapComparison struct {
+	Lap       Lap
+	Reference Lap
+	// Color is OverlayColorSpeed or OverlayColorDelta
+	Color string
+	// Corner is the braking zone of the reference lap to zoom into, 0 for the whole track
+	Corner int
+}
+
+// getTimeAtDistance returns the time since the start of the lap at which the distance has been reached
+func getTimeAtDistance(lap Lap, distances []float32, distance float32) float64 {
+	for i, d := range distances {
+		if d >= distance {
+			return packageNumbersToDuration(lap.DataHistory[i].PackageID - lap.DataHistory[0].PackageID).Seconds()
+		}
+	}
+	return packageNumbersToDuration(lap.DataHistory[len(lap.DataHistory)-1].PackageID - lap.DataHistory[0].PackageID).Seconds()
+}
+
+// getHeatColor goes from blue for the minimum value to red for the maximum value
+func getHeatColor(value float32, min float32, max float32) string {
+	share := float32(0)
+	if max > min {
+		share = (value - min) / (max - min)
+	}
+	return fmt.Sprintf("rgb(%d,0,%d)", int(255*share), int(255*(1-share)))
+}
+
+// getDeltaColor is green if time is gained compared to the reference and red if time is lost
+func getDeltaColor(delta float64) string {
+	intensity := math.Min(math.Abs(delta)/maxDeltaPerSegment, 1)
+	value := 255 - int(255*intensity)
+	if delta < 0 {
+		return fmt.Sprintf("rgb(%d,255,%d)", value, value)
+	}
+	return fmt.Sprintf("rgb(255,%d,%d)", value, value)
+}
+
+func getSpeedRange(laps ...Lap) (float32, float32) {
+	minSpeed, maxSpeed := float32(math.MaxFloat32), float32(0)
+	for _, lap := range laps {
+		for _, data := range lap.DataHistory {
+			minSpeed = float32(math.Min(float64(minSpeed), float64(data.CarSpeed)))
+			maxSpeed = float32(math.Max(float64(maxSpeed), float64(data.CarSpeed)))
+		}
+	}
+	return minSpeed, maxSpeed
+}
+
+// getBounds returns the maximum and minimum coordinates, unlike getMaxMinValuesForCoordinates it
+// works for sections of the track with only negative coordinates
+func getBounds(history []gt7.GTData) (float64, float64, float64, float64) {
+	maxx, maxz := math.Inf(-1), math.Inf(-1)
+	minx, minz := math.Inf(1), math.Inf(1)
+	for _, data := range history {
+		maxx = math.Max(maxx, float64(data.PositionX))
+		maxz = math.Max(maxz, float64(data.PositionZ))
+		minx = math.Min(minx, float64(data.PositionX))
+		minz = math.Min(minz, float64(data.PositionZ))
+	}
+	return maxx, maxz, minx, minz
+}
+
+// getCornerData returns the packages of the reference lap from the brake point of the corner on
+func getCornerData(reference Lap, corner int) ([]gt7.GTData, error) {
+	zones := GetBrakingZones(reference)
+	if corner < 1 || corner > len(zones) {
+		return nil, fmt.Errorf("corner %d not found, the reference lap has %d corners", corner, len(zones))
+	}
+	zone := zones[corner-1]
+	distances := reference.GetDistances()
+	end := zone.startIndex
+	for end < len(distances)-1 && distances[end]-zone.Start < cornerZoomDistance {
+		end++
+	}
+	return reference.DataHistory[zone.startIndex : end+1], nil
+}
+
+// drawColoredLine draws the lap segment by segment, every segment gets its own color
+func drawColoredLine(canvas *svg.SVG, lap Lap, width int, color func(from int, to int) string) {
+	for i := overlayDetail; i < len(lap.DataHistory); i += overlayDetail {
+		from := lap.DataHistory[i-overlayDetail]
+		to := lap.DataHistory[i]
+		canvas.Line(int(from.PositionX), int(from.PositionZ), int(to.PositionX), int(to.PositionZ),
+			fmt.Sprintf("stroke:%s;stroke-width:%d;stroke-linecap:round", color(i-overlayDetail, i), width))
+	}
+}
+
+// DrawLapComparisonSVG overlays the lines of two laps, the lap is colored by speed or by the time
+// gained or lost against the reference lap
+func DrawLapComparisonSVG(comparison LapComparison) (string, error) {
+	lap, reference := comparison.Lap, comparison.Reference
+	if len(lap.DataHistory) == 0 || len(reference.DataHistory) == 0 {
+		return "", fmt.Errorf("both laps need data to be compared")
+	}
+	if comparison.Color != OverlayColorSpeed && comparison.Color != OverlayColorDelta {
+		return "", fmt.Errorf("unknown color %q, use %s or %s", comparison.Color, OverlayColorSpeed, OverlayColorDelta)
+	}
+
+	view := append(append([]gt7.GTData{}, lap.DataHistory...), reference.DataHistory...)
+	if comparison.Corner > 0 {
+		var err error
+		view, err = getCornerData(reference, comparison.Corner)
+		if err != nil {
+			return "", err
+		}
+	}
+	maxx, maxz, minx, minz := getBounds(view)
+	width := int(maxx-minx) + 2*mapPadding
+	height := int(maxz-minz) + 2*mapPadding
+
+	buf := new(bytes.Buffer)
+	canvas := svg.New(buf)
+	canvas.Startview(width, height, int(minx)-mapPadding, int(minz)-mapPadding, width, height)
+
+	minSpeed, maxSpeed := getSpeedRange(lap, reference)
+	referenceDistances := reference.GetDistances()
+	lapDistances := lap.GetDistances()
+
+	// the reference is drawn below and thinner
+	drawColoredLine(canvas, reference, 4, func(from int, to int) string {
+		if comparison.Color == OverlayColorSpeed {
+			return getHeatColor(reference.DataHistory[to].CarSpeed, minSpeed, maxSpeed)
+		}
+		return "grey"
+	})
+	drawColoredLine(canvas, lap, 8, func(from int, to int) string {
+		if comparison.Color == OverlayColorSpeed {
+			return getHeatColor(lap.DataHistory[to].CarSpeed, minSpeed, maxSpeed)
+		}
+		lapTime := packageNumbersToDuration(lap.DataHistory[to].PackageID - lap.DataHistory[from].PackageID).Seconds()
+		referenceTime := getTimeAtDistance(reference, referenceDistances, lapDistances[to]) -
+			getTimeAtDistance(reference, referenceDistances, lapDistances[from])
+		return getDeltaColor(lapTime - referenceTime)
+	})
+
+	canvas.End()
+	return buf.String(), nil
+}
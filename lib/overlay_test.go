@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// getLapOnStraight places the lap on a straight along the x axis, the car drives with the speed
+func getLapOnStraight(speed float32) Lap {
+	lap := getLapWithBrakingZones(time.Minute, []int32{500}, 40)
+	for i := range lap.DataHistory {
+		lap.DataHistory[i].CarSpeed = speed
+		lap.DataHistory[i].PositionX = -float32(i)
+		lap.DataHistory[i].PositionZ = -100
+	}
+	return lap
+}
+
+func Test_getDeltaColor(t *testing.T) {
+	assert.Equal(t, "rgb(0,255,0)", getDeltaColor(-1))
+	assert.Equal(t, "rgb(255,0,0)", getDeltaColor(1))
+	assert.Equal(t, "rgb(255,255,255)", getDeltaColor(0))
+}
+
+func Test_getHeatColor(t *testing.T) {
+	assert.Equal(t, "rgb(0,0,255)", getHeatColor(80, 80, 180))
+	assert.Equal(t, "rgb(255,0,0)", getHeatColor(180, 80, 180))
+}
+
+func TestDrawLapComparisonSVG(t *testing.T) {
+	slow := getLapOnStraight(100)
+	fast := getLapOnStraight(200)
+
+	svg, err := DrawLapComparisonSVG(LapComparison{Lap: slow, Reference: fast, Color: OverlayColorDelta})
+	assert.NoError(t, err)
+	assert.Contains(t, svg, "<svg")
+	// the slower lap loses time everywhere
+	assert.Contains(t, svg, "stroke:rgb(255,")
+	assert.NotContains(t, svg, "stroke:rgb(0,255,0)")
+
+	svg, err = DrawLapComparisonSVG(LapComparison{Lap: fast, Reference: slow, Color: OverlayColorSpeed})
+	assert.NoError(t, err)
+	assert.Contains(t, svg, "stroke:rgb(255,0,0)")
+	assert.Contains(t, svg, "stroke:rgb(0,0,255)")
+
+	_, err = DrawLapComparisonSVG(LapComparison{Lap: fast, Reference: slow, Color: "throttle"})
+	assert.Error(t, err)
+	_, err = DrawLapComparisonSVG(LapComparison{Lap: Lap{}, Reference: slow, Color: OverlayColorSpeed})
+	assert.Error(t, err)
+}
+
+func TestDrawLapComparisonSVG_corner(t *testing.T) {
+	lap := getLapOnStraight(180)
+
+	track, err := DrawLapComparisonSVG(LapComparison{Lap: lap, Reference: lap, Color: OverlayColorSpeed})
+	assert.NoError(t, err)
+	corner, err := DrawLapComparisonSVG(LapComparison{Lap: lap, Reference: lap, Color: OverlayColorSpeed, Corner: 1})
+	assert.NoError(t, err)
+	// the view box spans 300m after the brake point at -500 plus the padding
+	assert.Contains(t, corner, `viewBox="-906 -130 436 60"`)
+	assert.NotContains(t, track, `viewBox="-906 -130 436 60"`)
+
+	_, err = DrawLapComparisonSVG(LapComparison{Lap: lap, Reference: lap, Color: OverlayColorSpeed, Corner: 2})
+	assert.Error(t, err)
+}
@@ -0,0 +1,198 @@
+package lib
+
+import (
+	"fmt"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/snipem/gt7fuel/lib/experimental"
+	"log"
+	"math"
+	"time"
+)
+
+// DefaultPitLoss is the time lost by a pit stop as long as no pit stop has been measured
+const DefaultPitLoss = 30 * time.Second
+
+// stationarySpeed is the speed in km/h below which the car counts as standing
+const stationarySpeed = 1
+
+// minFuelAdded is the fuel that has to be added while standing to count as refuelling,
+// below that it is noise in the fuel reading
+const minFuelAdded = 0.5
+
+// minFuelRises is the number of packages the fuel has to rise in while standing. Refuelling fills
+// the tank steadily, noise in the fuel reading after a spin jumps only once
+const minFuelRises = 3
+
+// pitPositionTolerance is the distance in meters to the position of an earlier pit stop a pit stop
+// has to be in, the car does not stop at exactly the same spot every time
+const pitPositionTolerance = 50
+
+// minTireChange is the increase in percent of a tire reading that counts as changed tires
+const minTireChange = 10
+
+type PitStop struct {
+	Entry          time.Time
+	StationaryTime time.Duration
+	FuelAdded      float32
+	TiresChanged   bool
+	// PositionX and PositionZ are where the car stood in the pit
+	PositionX float32
+	PositionZ float32
+	// TimeLost is the time lost in the lap into the pit and the out lap compared to the average regular lap
+	TimeLost time.Duration
+}
+
+func (p PitStop) String() string {
+	tires := ""
+	if p.TiresChanged {
+		tires = ", tires changed"
+	}
+	return fmt.Sprintf("%.1fs stationary, %+.1f fuel%s, %.1fs lost", p.StationaryTime.Seconds(), p.FuelAdded, tires, p.TimeLost.Seconds())
+}
+
+func formatPitStops(pitStops []PitStop) []string {
+	formatted := []string{}
+	for _, pitStop := range pitStops {
+		formatted = append(formatted, pitStop.String())
+	}
+	return formatted
+}
+
+// pitDetector follows the car while it is standing to detect refuelling and tire changes
+type pitDetector struct {
+	stationary   bool
+	since        time.Time
+	position     gt7.GTData
+	fuelAtStop   float32
+	tiresAtStop  experimental.TireData
+	fuelAdded    float32
+	lastFuel     float32
+	fuelRises    int
+	tiresChanged bool
+}
+
+// detectPitStop is called for every package and adds a pit stop to the ongoing lap when the car
+// leaves after it has been refuelled or got new tires
+func (s *Stats) detectPitStop(ld *gt7.GTData) {
+	d := &s.pitDetector
+
+	if ld.CarSpeed < stationarySpeed {
+		if !d.stationary {
+			*d = pitDetector{
+				stationary:  true,
+				since:       s.clock.Now(),
+				position:    *ld,
+				fuelAtStop:  ld.CurrentFuel,
+				lastFuel:    ld.CurrentFuel,
+				tiresAtStop: *s.LastTireData,
+			}
+		}
+		if ld.CurrentFuel > d.lastFuel {
+			d.fuelRises++
+		}
+		d.lastFuel = ld.CurrentFuel
+		if ld.CurrentFuel-d.fuelAtStop > d.fuelAdded {
+			d.fuelAdded = ld.CurrentFuel - d.fuelAtStop
+		}
+		if !d.tiresAtStop.LastWrite.IsZero() && s.LastTireData.LastWrite.After(d.tiresAtStop.LastWrite) {
+			d.tiresChanged = d.tiresChanged || hasTiresChanged(d.tiresAtStop, *s.LastTireData)
+		}
+		return
+	}
+
+	refuelled := d.fuelAdded >= minFuelAdded && d.fuelRises >= minFuelRises
+	if d.stationary && (refuelled || d.tiresChanged) && s.isAtPit(d.position) {
+		pitStop := PitStop{
+			Entry:          d.since,
+			StationaryTime: s.clock.Now().Sub(d.since),
+			FuelAdded:      d.fuelAdded,
+			TiresChanged:   d.tiresChanged,
+			PositionX:      d.position.PositionX,
+			PositionZ:      d.position.PositionZ,
+		}
+		log.Printf("PIT STOP 🔧 %s\n", pitStop)
+		s.OngoingLap.PitStops = append(s.OngoingLap.PitStops, pitStop)
+	}
+	d.stationary = false
+}
+
+// isAtPit tells if the car stands near an earlier pit stop, as long as there was none every position counts
+func (s *Stats) isAtPit(data gt7.GTData) bool {
+	pitStops := s.OngoingLap.PitStops
+	for _, lap := range s.Laps {
+		pitStops = append(pitStops, lap.PitStops...)
+	}
+	if len(pitStops) == 0 {
+		return true
+	}
+	for _, pitStop := range pitStops {
+		if math.Hypot(float64(data.PositionX-pitStop.PositionX), float64(data.PositionZ-pitStop.PositionZ)) <= pitPositionTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTiresChanged(before experimental.TireData, after experimental.TireData) bool {
+	return after.FrontLeft-before.FrontLeft >= minTireChange ||
+		after.FrontRight-before.FrontRight >= minTireChange ||
+		after.RearLeft-before.RearLeft >= minTireChange ||
+		after.RearRight-before.RearRight >= minTireChange
+}
+
+// getAverageRegularLapTime is the reference to measure the time lost in pit stops with
+func getAverageRegularLapTime(laps []Lap) (time.Duration, error) {
+	var total time.Duration
+	n := 0
+	for _, lap := range laps {
+		if lap.IsRegularLap() {
+			total += lap.Duration
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("no regular laps found")
+	}
+	return total / time.Duration(n), nil
+}
+
+// accountPitStopTimeLost adds the time lost of the last finished lap to its pit stops, or if it is the
+// out lap to the pit stops of the lap before
+func accountPitStopTimeLost(laps []Lap) {
+	if len(laps) == 0 {
+		return
+	}
+	reference, err := getAverageRegularLapTime(laps)
+	if err != nil {
+		return
+	}
+
+	lastLap := &laps[len(laps)-1]
+	if len(lastLap.PitStops) > 0 {
+		lastLap.PitStops[len(lastLap.PitStops)-1].TimeLost += lastLap.Duration - reference
+	}
+
+	if len(laps) > 1 {
+		lapBefore := &laps[len(laps)-2]
+		if len(lapBefore.PitStops) > 0 && len(lastLap.PitStops) == 0 {
+			lapBefore.PitStops[len(lapBefore.PitStops)-1].TimeLost += lastLap.Duration - reference
+		}
+	}
+}
+
+// GetPitLoss returns the average time lost by the pit stops of this race or DefaultPitLoss
+// if there has not been a pit stop yet
+func (s *Stats) GetPitLoss() time.Duration {
+	var total time.Duration
+	n := 0
+	for _, lap := range s.Laps {
+		for _, pitStop := range lap.PitStops {
+			total += pitStop.TimeLost
+			n++
+		}
+	}
+	if n == 0 || total <= 0 {
+		return DefaultPitLoss
+	}
+	return total / time.Duration(n)
+}
@@ -0,0 +1,130 @@
+package lib
+
+import (
+	"github.com/jmhodges/clock"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/snipem/gt7fuel/lib/experimental"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestStats_detectPitStop(t *testing.T) {
+	t.Run("Refuelling", func(t *testing.T) {
+		s := NewStats()
+		fakeClock := clock.NewFake()
+		s.setClock(fakeClock)
+
+		s.detectPitStop(&gt7.GTData{CarSpeed: 80, CurrentFuel: 10})
+		s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: 10, PositionX: 100, PositionZ: -50})
+		fakeClock.Add(5 * time.Second)
+		s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: 50})
+		s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: 75})
+		fakeClock.Add(5 * time.Second)
+		s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: 100})
+		assert.Len(t, s.OngoingLap.PitStops, 0, "pit stop is recorded when leaving")
+
+		s.detectPitStop(&gt7.GTData{CarSpeed: 5, CurrentFuel: 100})
+		assert.Len(t, s.OngoingLap.PitStops, 1)
+		pitStop := s.OngoingLap.PitStops[0]
+		assert.Equal(t, float32(90), pitStop.FuelAdded)
+		assert.Equal(t, 10*time.Second, pitStop.StationaryTime)
+		assert.False(t, pitStop.TiresChanged)
+		assert.Equal(t, float32(100), pitStop.PositionX)
+		assert.Equal(t, float32(-50), pitStop.PositionZ)
+		assert.True(t, s.OngoingLap.IsLapIntoPit())
+	})
+
+	t.Run("Tire change", func(t *testing.T) {
+		s := NewStats()
+		fakeClock := clock.NewFake()
+		s.setClock(fakeClock)
+		s.LastTireData = &experimental.TireData{FrontLeft: 50, FrontRight: 50, RearLeft: 60, RearRight: 60, LastWrite: fakeClock.Now()}
+
+		s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: 10})
+		fakeClock.Add(10 * time.Second)
+		s.LastTireData = &experimental.TireData{FrontLeft: 100, FrontRight: 100, RearLeft: 100, RearRight: 100, LastWrite: fakeClock.Now()}
+		s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: 10})
+		s.detectPitStop(&gt7.GTData{CarSpeed: 5, CurrentFuel: 10})
+
+		assert.Len(t, s.OngoingLap.PitStops, 1)
+		assert.True(t, s.OngoingLap.PitStops[0].TiresChanged)
+		assert.Equal(t, float32(0), s.OngoingLap.PitStops[0].FuelAdded)
+	})
+
+	t.Run("Standing without service", func(t *testing.T) {
+		s := NewStats()
+		s.setClock(clock.NewFake())
+
+		s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: 10})
+		s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: 10.2})
+		s.detectPitStop(&gt7.GTData{CarSpeed: 5, CurrentFuel: 10})
+		assert.Len(t, s.OngoingLap.PitStops, 0)
+	})
+
+	t.Run("Noise in the fuel reading after a spin", func(t *testing.T) {
+		s := NewStats()
+		s.setClock(clock.NewFake())
+
+		s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: 10})
+		s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: 11})
+		s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: 11})
+		s.detectPitStop(&gt7.GTData{CarSpeed: 5, CurrentFuel: 11})
+		assert.Len(t, s.OngoingLap.PitStops, 0)
+	})
+
+	t.Run("Away from the pit", func(t *testing.T) {
+		s := NewStats()
+		fakeClock := clock.NewFake()
+		s.setClock(fakeClock)
+		s.Laps = []Lap{{Number: 1, PitStops: []PitStop{{PositionX: 100, PositionZ: -50}}}}
+		s.LastTireData = &experimental.TireData{FrontLeft: 50, LastWrite: fakeClock.Now()}
+
+		// a misread of the tires while standing on track after a spin
+		s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: 10, PositionX: 900, PositionZ: 300})
+		fakeClock.Add(time.Second)
+		s.LastTireData = &experimental.TireData{FrontLeft: 100, LastWrite: fakeClock.Now()}
+		s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: 10, PositionX: 900, PositionZ: 300})
+		s.detectPitStop(&gt7.GTData{CarSpeed: 5, CurrentFuel: 10, PositionX: 900, PositionZ: 300})
+		assert.Len(t, s.OngoingLap.PitStops, 0)
+
+		// near the pit stop of the first lap
+		s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: 10, PositionX: 110, PositionZ: -40})
+		for fuel := float32(20); fuel <= 50; fuel += 10 {
+			s.detectPitStop(&gt7.GTData{CarSpeed: 0, CurrentFuel: fuel, PositionX: 110, PositionZ: -40})
+		}
+		s.detectPitStop(&gt7.GTData{CarSpeed: 5, CurrentFuel: 50, PositionX: 110, PositionZ: -40})
+		assert.Len(t, s.OngoingLap.PitStops, 1)
+	})
+}
+
+func Test_accountPitStopTimeLost(t *testing.T) {
+	laps := []Lap{
+		{Number: 1, Duration: 70 * time.Second},
+		{Number: 2, Duration: 60 * time.Second},
+		{Number: 3, Duration: 62 * time.Second},
+	}
+	accountPitStopTimeLost(laps)
+
+	// Lap into the pit
+	laps = append(laps, Lap{Number: 4, Duration: 80 * time.Second, PitStops: []PitStop{{FuelAdded: 50}}})
+	laps[3].PreviousLap = &laps[2]
+	accountPitStopTimeLost(laps)
+	assert.Equal(t, 19*time.Second, laps[3].PitStops[0].TimeLost)
+
+	// Out lap
+	laps = append(laps, Lap{Number: 5, Duration: 65 * time.Second})
+	laps[4].PreviousLap = &laps[3]
+	accountPitStopTimeLost(laps)
+	assert.Equal(t, 23*time.Second, laps[3].PitStops[0].TimeLost)
+
+	s := NewStats()
+	assert.Equal(t, DefaultPitLoss, s.GetPitLoss())
+	s.Laps = laps
+	assert.Equal(t, 23*time.Second, s.GetPitLoss())
+}
+
+func TestPitStop_String(t *testing.T) {
+	pitStop := PitStop{StationaryTime: 12300 * time.Millisecond, FuelAdded: 45, TiresChanged: true, TimeLost: 32 * time.Second}
+	assert.Equal(t, "12.3s stationary, +45.0 fuel, tires changed, 32.0s lost", pitStop.String())
+}
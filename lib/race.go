@@ -31,7 +31,12 @@ func LogTick(ld *gt7.GTData, gt7stats *Stats, raceTimeInMinutes *int) bool {
 
 		gt7stats.History.Update(*ld)
 
+		gt7stats.detectPitStop(ld)
+		gt7stats.detectWheelSlip(ld)
+		gt7stats.monitorHealth(ld)
+
 		gt7stats.OngoingLap.DataHistory = append(gt7stats.OngoingLap.DataHistory, *ld)
+		gt7stats.refreshTrackMap()
 
 		gt7stats.SetManualSetRaceDuration(time.Duration(*raceTimeInMinutes) * time.Minute)
 
@@ -75,11 +80,32 @@ func finishLap(ld *gt7.GTData, gt7stats *Stats) {
 	gt7stats.OngoingLap.FuelEnd = ld.CurrentFuel
 	gt7stats.OngoingLap.Duration = GetDurationFromGT7Time(ld.LastLap)
 	gt7stats.OngoingLap.TiresEnd = *gt7stats.LastTireData
+	gt7stats.OngoingLap.TireTemperatures = GetTireTemperatures(gt7stats.OngoingLap, gt7stats.Settings.TireTempWindow)
+	gt7stats.OngoingLap.brakingZones = segmentBrakingZones(gt7stats.OngoingLap)
+
+	// the racing line is the best lap so far, without one off track excursions are not detected
+	racingLine, err := gt7stats.GetBestLap()
+	if err != nil {
+		racingLine = Lap{}
+	}
+	gt7stats.OngoingLap.Incidents = DetectIncidents(gt7stats.OngoingLap, racingLine)
+	if gt7stats.OngoingLap.IsIncidentLap() {
+		log.Printf("INCIDENT 💥 in lap %d: %v\n", gt7stats.OngoingLap.Number, gt7stats.OngoingLap.Incidents)
+	}
 
 	log.Printf("Add new Lap. Last Lap was: %s\n", gt7stats.OngoingLap)
 
+	if gt7stats.Store != nil {
+		err := gt7stats.Store.AddLap(newStoredLap(ld.CarID, gt7stats.Track, gt7stats.sessionID, gt7stats.OngoingLap, gt7stats.Settings, gt7stats.clock.Now()))
+		if err != nil {
+			log.Printf("Error storing lap: %v\n", err)
+		}
+	}
+
 	oldOngoingLap := gt7stats.OngoingLap
 	gt7stats.Laps = append(gt7stats.Laps, gt7stats.OngoingLap)
+	accountPitStopTimeLost(gt7stats.Laps)
+	gt7stats.learnHealthRanges()
 	resetOngoingLap(ld, gt7stats)
 	// New lap from here
 	gt7stats.OngoingLap.PreviousLap = &oldOngoingLap
@@ -0,0 +1,590 @@
+package lib
+
+import (
+	"encoding/xml"
+	"fmt"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxRasterSize is the largest width or height of a rasterized image in pixels
+const maxRasterSize = 4096
+
+// maxRasterPixels is the largest number of pixels of a rasterized image, every pixel takes 4 bytes
+const maxRasterPixels = 4096 * 2048
+
+// defaultFontSize is the font size in pixels of text without a font-size
+const defaultFontSize = 16
+
+var namedColors = map[string]color.RGBA{
+	"black":       {0, 0, 0, 255},
+	"white":       {255, 255, 255, 255},
+	"grey":        {128, 128, 128, 255},
+	"gray":        {128, 128, 128, 255},
+	"red":         {255, 0, 0, 255},
+	"darkred":     {139, 0, 0, 255},
+	"orangered":   {255, 69, 0, 255},
+	"orange":      {255, 165, 0, 255},
+	"yellow":      {255, 255, 0, 255},
+	"yellowgreen": {154, 205, 50, 255},
+	"lime":        {0, 255, 0, 255},
+	"green":       {0, 128, 0, 255},
+	"teal":        {0, 128, 128, 255},
+	"blue":        {0, 0, 255, 255},
+	"lightblue":   {173, 216, 230, 255},
+	"purple":      {128, 0, 128, 255},
+}
+
+// affine is the transformation matrix [a b c d e f] of SVG, x' = a*x + c*y + e and y' = b*x + d*y + f
+type affine [6]float64
+
+var identity = affine{1, 0, 0, 1, 0, 0}
+
+func (m affine) multiply(n affine) affine {
+	return affine{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+func (m affine) apply(x float64, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// scale is the factor lengths like stroke widths are scaled with
+func (m affine) scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+func parseNumbers(s string) ([]float64, error) {
+	numbers := []float64{}
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' }) {
+		n, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing number %q: %v", field, err)
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+// parseTransform supports translate, rotate and scale
+func parseTransform(s string) (affine, error) {
+	m := identity
+	for _, part := range strings.Split(s, ")") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		nameAndArgs := strings.SplitN(part, "(", 2)
+		if len(nameAndArgs) != 2 {
+			return m, fmt.Errorf("invalid transform %q", s)
+		}
+		args, err := parseNumbers(nameAndArgs[1])
+		if err != nil || len(args) == 0 {
+			return m, fmt.Errorf("invalid arguments in transform %q", s)
+		}
+		switch strings.TrimSpace(nameAndArgs[0]) {
+		case "translate":
+			args = append(args, 0)
+			m = m.multiply(affine{1, 0, 0, 1, args[0], args[1]})
+		case "rotate":
+			a := args[0] * math.Pi / 180
+			m = m.multiply(affine{math.Cos(a), math.Sin(a), -math.Sin(a), math.Cos(a), 0, 0})
+		case "scale":
+			args = append(args, args[0])
+			m = m.multiply(affine{args[0], 0, 0, args[1], 0, 0})
+		default:
+			return m, fmt.Errorf("unsupported transform %q", nameAndArgs[0])
+		}
+	}
+	return m, nil
+}
+
+func parseColor(s string) (color.RGBA, bool) {
+	s = strings.TrimSpace(s)
+	if c, ok := namedColors[s]; ok {
+		return c, true
+	}
+	if strings.HasPrefix(s, "rgb(") && strings.HasSuffix(s, ")") {
+		values, err := parseNumbers(s[4 : len(s)-1])
+		if err != nil || len(values) != 3 {
+			return color.RGBA{}, false
+		}
+		return color.RGBA{uint8(values[0]), uint8(values[1]), uint8(values[2]), 255}, true
+	}
+	if strings.HasPrefix(s, "#") && (len(s) == 7 || len(s) == 4) {
+		hex := s[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return color.RGBA{}, false
+		}
+		return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, true
+	}
+	return color.RGBA{}, false
+}
+
+// rasterStyle holds the inherited presentation attributes of an element
+type rasterStyle map[string]string
+
+func (s rasterStyle) with(element xml.StartElement) rasterStyle {
+	style := rasterStyle{}
+	for k, v := range s {
+		style[k] = v
+	}
+	for _, attr := range element.Attr {
+		switch attr.Name.Local {
+		case "style":
+			for _, declaration := range strings.Split(attr.Value, ";") {
+				kv := strings.SplitN(declaration, ":", 2)
+				if len(kv) == 2 {
+					style[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+				}
+			}
+		case "fill", "stroke", "stroke-width", "opacity", "fill-opacity", "stroke-opacity", "visibility":
+			style[attr.Name.Local] = attr.Value
+		}
+	}
+	return style
+}
+
+func (s rasterStyle) number(name string, fallback float64) float64 {
+	n, err := strconv.ParseFloat(strings.TrimSuffix(s[name], "px"), 64)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
+type gradientStop struct {
+	offset float64
+	color  color.RGBA
+}
+
+// paint returns the color of the pixel at the position
+type paint func(x float64, y float64) color.RGBA
+
+type rasterizer struct {
+	img       *image.RGBA
+	gradients map[string][]gradientStop
+}
+
+func (r *rasterizer) blend(x int, y int, c color.RGBA, alpha float64) {
+	if !(image.Point{x, y}.In(r.img.Rect)) || alpha <= 0 {
+		return
+	}
+	alpha = math.Min(alpha, 1) * float64(c.A) / 255
+	old := r.img.RGBAAt(x, y)
+	mix := func(a uint8, b uint8) uint8 { return uint8(float64(a)*(1-alpha) + float64(b)*alpha + 0.5) }
+	r.img.SetRGBA(x, y, color.RGBA{mix(old.R, c.R), mix(old.G, c.G), mix(old.B, c.B), 255})
+}
+
+// shade calls coverage for every pixel in the bounding box, coverage returns how much the pixel is covered
+func (r *rasterizer) shade(minx, miny, maxx, maxy float64, p paint, alpha float64, coverage func(x float64, y float64) float64) {
+	bounds := r.img.Rect
+	x0, y0 := int(math.Max(math.Floor(minx), float64(bounds.Min.X))), int(math.Max(math.Floor(miny), float64(bounds.Min.Y)))
+	x1, y1 := int(math.Min(math.Ceil(maxx), float64(bounds.Max.X-1))), int(math.Min(math.Ceil(maxy), float64(bounds.Max.Y-1)))
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			cx, cy := float64(x)+0.5, float64(y)+0.5
+			if c := coverage(cx, cy); c > 0 {
+				r.blend(x, y, p(cx, cy), c*alpha)
+			}
+		}
+	}
+}
+
+func distanceToSegment(px, py, x1, y1, x2, y2 float64) float64 {
+	dx, dy := x2-x1, y2-y1
+	t := 0.0
+	if dx != 0 || dy != 0 {
+		t = math.Max(0, math.Min(1, ((px-x1)*dx+(py-y1)*dy)/(dx*dx+dy*dy)))
+	}
+	return math.Hypot(px-(x1+t*dx), py-(y1+t*dy))
+}
+
+// strokeSegment draws a line with round caps and anti aliased edges
+func (r *rasterizer) strokeSegment(x1, y1, x2, y2, width float64, p paint, alpha float64) {
+	half := math.Max(width, 1) / 2
+	r.shade(math.Min(x1, x2)-half-1, math.Min(y1, y2)-half-1, math.Max(x1, x2)+half+1, math.Max(y1, y2)+half+1, p, alpha,
+		func(x float64, y float64) float64 {
+			return math.Min(1, half+0.5-distanceToSegment(x, y, x1, y1, x2, y2))
+		})
+}
+
+// fillPolygon fills the polygon with the even odd rule
+func (r *rasterizer) fillPolygon(xs []float64, ys []float64, p paint, alpha float64) {
+	if len(xs) < 3 {
+		return
+	}
+	minx, miny, maxx, maxy := xs[0], ys[0], xs[0], ys[0]
+	for i := range xs {
+		minx, miny = math.Min(minx, xs[i]), math.Min(miny, ys[i])
+		maxx, maxy = math.Max(maxx, xs[i]), math.Max(maxy, ys[i])
+	}
+	r.shade(minx, miny, maxx, maxy, p, alpha, func(x float64, y float64) float64 {
+		inside := false
+		for i, j := 0, len(xs)-1; i < len(xs); j, i = i, i+1 {
+			if (ys[i] > y) != (ys[j] > y) && x < (xs[j]-xs[i])*(y-ys[i])/(ys[j]-ys[i])+xs[i] {
+				inside = !inside
+			}
+		}
+		if inside {
+			return 1
+		}
+		return 0
+	})
+}
+
+// getPaint returns the paint of the fill or stroke property, false if nothing is painted
+func (r *rasterizer) getPaint(value string, minx float64, maxx float64) (paint, bool) {
+	if strings.HasPrefix(value, "url(#") {
+		stops := r.gradients[strings.TrimSuffix(strings.TrimPrefix(value, "url(#"), ")")]
+		if len(stops) == 0 {
+			return nil, false
+		}
+		// only horizontal gradients are supported
+		return func(x float64, y float64) color.RGBA {
+			offset := 0.0
+			if maxx > minx {
+				offset = (x - minx) / (maxx - minx)
+			}
+			from, to := stops[0], stops[len(stops)-1]
+			for i := 1; i < len(stops); i++ {
+				if stops[i].offset >= offset {
+					from, to = stops[i-1], stops[i]
+					break
+				}
+			}
+			share := 0.0
+			if to.offset > from.offset {
+				share = math.Max(0, math.Min(1, (offset-from.offset)/(to.offset-from.offset)))
+			}
+			mix := func(a uint8, b uint8) uint8 { return uint8(float64(a)*(1-share) + float64(b)*share) }
+			return color.RGBA{mix(from.color.R, to.color.R), mix(from.color.G, to.color.G), mix(from.color.B, to.color.B), 255}
+		}, true
+	}
+	c, ok := parseColor(value)
+	if !ok {
+		return nil, false
+	}
+	return func(x float64, y float64) color.RGBA { return c }, true
+}
+
+func getAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func getNumberAttrs(element xml.StartElement, names ...string) []float64 {
+	values := []float64{}
+	for _, name := range names {
+		v, _ := strconv.ParseFloat(getAttr(element, name), 64)
+		values = append(values, v)
+	}
+	return values
+}
+
+// getPathPoints returns the subpaths of a path, only the commands M, L and z are supported
+func getPathPoints(d string) ([][][2]float64, error) {
+	subpaths := [][][2]float64{}
+	fields := strings.FieldsFunc(d, func(r rune) bool { return r == ',' || r == ' ' })
+	command := ""
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "M", "L":
+			command = fields[i]
+			continue
+		case "z", "Z":
+			if len(subpaths) > 0 && len(subpaths[len(subpaths)-1]) > 0 {
+				subpaths[len(subpaths)-1] = append(subpaths[len(subpaths)-1], subpaths[len(subpaths)-1][0])
+			}
+			continue
+		}
+		if i+1 >= len(fields) {
+			return nil, fmt.Errorf("incomplete path %q", d)
+		}
+		x, errX := strconv.ParseFloat(fields[i], 64)
+		y, errY := strconv.ParseFloat(fields[i+1], 64)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("unsupported path %q", d)
+		}
+		i++
+		if command == "M" || len(subpaths) == 0 {
+			subpaths = append(subpaths, [][2]float64{})
+			// following coordinates are lines
+			command = "L"
+		}
+		subpaths[len(subpaths)-1] = append(subpaths[len(subpaths)-1], [2]float64{x, y})
+	}
+	return subpaths, nil
+}
+
+// draw paints the shape given by its points in user space with the fill and stroke of the style
+func (r *rasterizer) draw(m affine, style rasterStyle, points [][2]float64, closed bool, circle []float64) {
+	if style["visibility"] == "hidden" || style["display"] == "none" {
+		return
+	}
+	opacity := style.number("opacity", 1)
+	xs, ys := []float64{}, []float64{}
+	for _, p := range points {
+		x, y := m.apply(p[0], p[1])
+		xs, ys = append(xs, x), append(ys, y)
+	}
+	minx, maxx := math.Inf(1), math.Inf(-1)
+	for _, x := range xs {
+		minx, maxx = math.Min(minx, x), math.Max(maxx, x)
+	}
+
+	fill := style["fill"]
+	if fill == "" {
+		fill = "black"
+	}
+	if p, ok := r.getPaint(fill, minx, maxx); ok && (closed || circle != nil) {
+		alpha := opacity * style.number("fill-opacity", 1)
+		if circle != nil {
+			cx, cy := m.apply(circle[0], circle[1])
+			radius := circle[2] * m.scale()
+			r.shade(cx-radius-1, cy-radius-1, cx+radius+1, cy+radius+1, p, alpha, func(x float64, y float64) float64 {
+				return math.Min(1, radius+0.5-math.Hypot(x-cx, y-cy))
+			})
+		} else {
+			r.fillPolygon(xs, ys, p, alpha)
+		}
+	}
+
+	p, ok := r.getPaint(style["stroke"], minx, maxx)
+	if !ok {
+		return
+	}
+	alpha := opacity * style.number("stroke-opacity", 1)
+	width := style.number("stroke-width", 1) * m.scale()
+	if circle != nil {
+		cx, cy := m.apply(circle[0], circle[1])
+		radius := circle[2] * m.scale()
+		r.shade(cx-radius-width, cy-radius-width, cx+radius+width, cy+radius+width, p, alpha, func(x float64, y float64) float64 {
+			return math.Min(1, width/2+0.5-math.Abs(math.Hypot(x-cx, y-cy)-radius))
+		})
+		return
+	}
+	for i := 1; i < len(xs); i++ {
+		r.strokeSegment(xs[i-1], ys[i-1], xs[i], ys[i], width, p, alpha)
+	}
+	if closed && len(xs) > 2 {
+		r.strokeSegment(xs[len(xs)-1], ys[len(ys)-1], xs[0], ys[0], width, p, alpha)
+	}
+}
+
+// drawText draws the text with the bitmap font of basicfont scaled to the font size. Like in SVG x and y
+// are the start of the baseline, the text-anchor moves the text to end or be centered there
+func (r *rasterizer) drawText(m affine, style rasterStyle, x float64, y float64, text string) {
+	if style["visibility"] == "hidden" || style["display"] == "none" || strings.TrimSpace(text) == "" {
+		return
+	}
+	fill := style["fill"]
+	if fill == "" {
+		fill = "black"
+	}
+	p, ok := r.getPaint(fill, 0, 0)
+	if !ok {
+		return
+	}
+
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, text).Ceil()
+	mask := image.NewAlpha(image.Rect(0, 0, width, face.Height))
+	drawer := font.Drawer{Dst: mask, Src: image.Opaque, Face: face, Dot: fixed.P(0, face.Ascent)}
+	drawer.DrawString(text)
+
+	scale := style.number("font-size", defaultFontSize) * m.scale() / float64(face.Height)
+	left, baseline := m.apply(x, y)
+	switch style["text-anchor"] {
+	case "middle":
+		left -= float64(width) * scale / 2
+	case "end":
+		left -= float64(width) * scale
+	}
+	top := baseline - float64(face.Ascent)*scale
+	alpha := style.number("opacity", 1) * style.number("fill-opacity", 1)
+	// the glyphs are sampled several times per pixel, so that scaling down does not drop their strokes
+	const samples = 4
+	r.shade(left, top, left+float64(width)*scale, top+float64(face.Height)*scale, p, alpha, func(px float64, py float64) float64 {
+		coverage := 0.0
+		for i := 0; i < samples; i++ {
+			for j := 0; j < samples; j++ {
+				sx := (px - 0.5 + (float64(i)+0.5)/samples - left) / scale
+				sy := (py - 0.5 + (float64(j)+0.5)/samples - top) / scale
+				coverage += float64(mask.AlphaAt(int(math.Floor(sx)), int(math.Floor(sy))).A) / 255
+			}
+		}
+		return coverage / (samples * samples)
+	})
+}
+
+// getViewBox returns the area of the user space that is shown, from the viewBox or the size of the svg
+func getViewBox(element xml.StartElement) ([]float64, error) {
+	if viewBox := getAttr(element, "viewBox"); viewBox != "" {
+		values, err := parseNumbers(viewBox)
+		if err != nil || len(values) != 4 {
+			return nil, fmt.Errorf("invalid viewBox %q", viewBox)
+		}
+		return values, nil
+	}
+	size := getNumberAttrs(element, "width", "height")
+	return []float64{0, 0, size[0], size[1]}, nil
+}
+
+// RasterizeSVG renders the SVGs drawn by this package to an image of the given width on a white
+// background, the height keeps the aspect ratio. Only the elements and attributes used by the
+// graphics of this package are supported, text is drawn with a bitmap font
+func RasterizeSVG(svgString string, width int) (*image.RGBA, error) {
+	if width <= 0 || width > maxRasterSize {
+		return nil, fmt.Errorf("width %d is not between 1 and %d", width, maxRasterSize)
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(svgString))
+	r := &rasterizer{gradients: map[string][]gradientStop{}}
+	transforms := []affine{}
+	styles := []rasterStyle{}
+	gradient := ""
+	// text is the text element whose characters are drawn next
+	var text *xml.StartElement
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing svg: %v", err)
+		}
+
+		if chars, ok := token.(xml.CharData); ok && text != nil {
+			v := getNumberAttrs(*text, "x", "y")
+			r.drawText(transforms[len(transforms)-1], styles[len(styles)-1], v[0], v[1], string(chars))
+			continue
+		}
+		if _, ok := token.(xml.EndElement); ok && len(transforms) > 0 {
+			text = nil
+			transforms = transforms[:len(transforms)-1]
+			styles = styles[:len(styles)-1]
+			continue
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if element.Name.Local == "svg" && r.img == nil {
+			viewBox, err := getViewBox(element)
+			if err != nil {
+				return nil, err
+			}
+			if viewBox[2] <= 0 || viewBox[3] <= 0 {
+				return nil, fmt.Errorf("svg has no size")
+			}
+			height := int(math.Round(float64(width) * viewBox[3] / viewBox[2]))
+			if height < 1 || height > maxRasterSize {
+				return nil, fmt.Errorf("height %d is not between 1 and %d", height, maxRasterSize)
+			}
+			if width*height > maxRasterPixels {
+				return nil, fmt.Errorf("image of %dx%d pixels is larger than %d pixels", width, height, maxRasterPixels)
+			}
+			r.img = image.NewRGBA(image.Rect(0, 0, width, height))
+			for i := range r.img.Pix {
+				r.img.Pix[i] = 255
+			}
+			scale := float64(width) / viewBox[2]
+			transforms = append(transforms, affine{scale, 0, 0, scale, -viewBox[0] * scale, -viewBox[1] * scale})
+			styles = append(styles, rasterStyle{})
+			continue
+		}
+		if r.img == nil {
+			return nil, fmt.Errorf("expected svg element, got %s", element.Name.Local)
+		}
+
+		m := transforms[len(transforms)-1]
+		if transform := getAttr(element, "transform"); transform != "" {
+			t, err := parseTransform(transform)
+			if err != nil {
+				return nil, err
+			}
+			m = m.multiply(t)
+		}
+		style := styles[len(styles)-1].with(element)
+		transforms = append(transforms, m)
+		styles = append(styles, style)
+
+		switch element.Name.Local {
+		case "linearGradient":
+			gradient = getAttr(element, "id")
+		case "stop":
+			c, _ := parseColor(getAttr(element, "stop-color"))
+			offset, _ := strconv.ParseFloat(strings.TrimSuffix(getAttr(element, "offset"), "%"), 64)
+			r.gradients[gradient] = append(r.gradients[gradient], gradientStop{offset: offset / 100, color: c})
+		case "line":
+			v := getNumberAttrs(element, "x1", "y1", "x2", "y2")
+			r.draw(m, style, [][2]float64{{v[0], v[1]}, {v[2], v[3]}}, false, nil)
+		case "polyline", "polygon":
+			values, err := parseNumbers(getAttr(element, "points"))
+			if err != nil {
+				return nil, err
+			}
+			points := [][2]float64{}
+			for i := 0; i+1 < len(values); i += 2 {
+				points = append(points, [2]float64{values[i], values[i+1]})
+			}
+			r.draw(m, style, points, element.Name.Local == "polygon", nil)
+		case "rect":
+			v := getNumberAttrs(element, "x", "y", "width", "height")
+			r.draw(m, style, [][2]float64{{v[0], v[1]}, {v[0] + v[2], v[1]}, {v[0] + v[2], v[1] + v[3]}, {v[0], v[1] + v[3]}}, true, nil)
+		case "circle":
+			v := getNumberAttrs(element, "cx", "cy", "r")
+			r.draw(m, style, [][2]float64{{v[0] - v[2], v[1]}, {v[0] + v[2], v[1]}}, false, v)
+		case "text":
+			text = &element
+		case "path":
+			subpaths, err := getPathPoints(getAttr(element, "d"))
+			if err != nil {
+				return nil, err
+			}
+			// paths are only stroked, filling them is not supported
+			style["fill"] = "none"
+			for _, points := range subpaths {
+				r.draw(m, style, points, false, nil)
+			}
+		}
+	}
+	if r.img == nil {
+		return nil, fmt.Errorf("no svg element found")
+	}
+	return r.img, nil
+}
+
+// WriteSVGAsPNG rasterizes the SVG with the given width and writes it as PNG
+func WriteSVGAsPNG(w io.Writer, svgString string, width int) error {
+	img, err := RasterizeSVG(svgString, width)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
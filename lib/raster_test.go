@@ -0,0 +1,108 @@
+package lib
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func Test_parseTransform(t *testing.T) {
+	m, err := parseTransform("translate(10,20) rotate(90)")
+	assert.NoError(t, err)
+	x, y := m.apply(1, 0)
+	assert.InDelta(t, 10, x, 0.001)
+	assert.InDelta(t, 21, y, 0.001)
+
+	_, err = parseTransform("skewX(10)")
+	assert.Error(t, err)
+}
+
+func Test_parseColor(t *testing.T) {
+	c, ok := parseColor("rgb(255,0,10)")
+	assert.True(t, ok)
+	assert.Equal(t, color.RGBA{255, 0, 10, 255}, c)
+	c, ok = parseColor("#00ff00")
+	assert.True(t, ok)
+	assert.Equal(t, color.RGBA{0, 255, 0, 255}, c)
+	_, ok = parseColor("none")
+	assert.False(t, ok)
+}
+
+func TestRasterizeSVG(t *testing.T) {
+	svg := `<?xml version="1.0"?>
+<svg width="100" height="50" viewBox="-50 0 100 50" xmlns="http://www.w3.org/2000/svg">
+<rect x="-50" y="0" width="50" height="50" style="fill:red"/>
+<line x1="0" y1="25" x2="50" y2="25" style="stroke:blue;stroke-width:10"/>
+<g visibility="hidden"><circle cx="25" cy="10" r="5" style="fill:black"/></g>
+</svg>`
+
+	img, err := RasterizeSVG(svg, 200)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, img.Bounds().Dx())
+	assert.Equal(t, 100, img.Bounds().Dy())
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, img.RGBAAt(50, 50))
+	assert.Equal(t, color.RGBA{0, 0, 255, 255}, img.RGBAAt(150, 50))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, img.RGBAAt(150, 20), "hidden elements are not drawn")
+
+	_, err = RasterizeSVG(svg, 0)
+	assert.Error(t, err)
+	_, err = RasterizeSVG(svg, 8000)
+	assert.Error(t, err, "too wide")
+	_, err = RasterizeSVG(`<svg viewBox="0 0 100 100"></svg>`, 4096)
+	assert.Error(t, err, "too many pixels")
+	_, err = RasterizeSVG("<html></html>", 100)
+	assert.Error(t, err)
+}
+
+// countDarkPixels counts the pixels in the rectangle that are darker than light grey
+func countDarkPixels(img *image.RGBA, rect image.Rectangle) int {
+	dark := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if img.RGBAAt(x, y).R < 128 {
+				dark++
+			}
+		}
+	}
+	return dark
+}
+
+func TestRasterizeSVGText(t *testing.T) {
+	svg := `<svg width="100" height="50" viewBox="0 0 100 50">
+<text x="50" y="30" style="font-size:20px;text-anchor:middle">P 12</text>
+<text x="0" y="10" visibility="hidden">hidden</text>
+</svg>`
+	img, err := RasterizeSVG(svg, 200)
+	assert.NoError(t, err)
+	// the text is centered at x 50 and twice as large with the doubled width
+	assert.Greater(t, countDarkPixels(img, image.Rect(50, 20, 150, 60)), 50)
+	assert.Equal(t, 0, countDarkPixels(img, image.Rect(0, 0, 50, 100)))
+	assert.Equal(t, 0, countDarkPixels(img, image.Rect(150, 0, 200, 100)))
+
+	// the labels of the charts are drawn
+	img, err = RasterizeSVG(DrawLapTimesSVG(getRaceLaps()), 400)
+	assert.NoError(t, err)
+	assert.Greater(t, countDarkPixels(img, image.Rect(0, 0, 400, 20)), 0)
+}
+
+func TestWriteSVGAsPNG(t *testing.T) {
+	lap := getLapOnStraight(180)
+	for _, svg := range []string{DrawLapToSVG(lap), DrawRideHeightBySpeedSVG(GetRideHeightBySpeed(lap))} {
+		buf := new(bytes.Buffer)
+		assert.NoError(t, WriteSVGAsPNG(buf, svg, 300))
+		img, err := png.Decode(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, 300, img.Bounds().Dx())
+	}
+
+	heatmap, err := DrawLapHeatmapSVG(lap, HeatmapSpeed)
+	assert.NoError(t, err)
+	trace, err := DrawTraceSVG(lap, Lap{}, TraceSpeed)
+	assert.NoError(t, err)
+	for _, svg := range []string{heatmap, trace} {
+		assert.NoError(t, WriteSVGAsPNG(new(bytes.Buffer), svg, 300))
+	}
+}
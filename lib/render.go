@@ -0,0 +1,213 @@
+package lib
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"sport": GetSportFormat,
+	"t":     Translate,
+	"lines": func(lines []string) template.HTML {
+		escaped := []string{}
+		for _, line := range lines {
+			escaped = append(escaped, template.HTMLEscapeString(line))
+		}
+		return template.HTML(strings.Join(escaped, "<br>"))
+	},
+}).ParseFS(templateFiles, "templates/*.html"))
+
+const LanguageEnglish = "en"
+const LanguageGerman = "de"
+
+var Languages = []string{LanguageEnglish, LanguageGerman}
+
+// translations map the English texts to the other languages, missing texts stay English
+var translations = map[string]map[string]string{
+	LanguageGerman: {
+		NoStartDetected:              "Noch kein Start erfasst",
+		"Race Time":                  "Rennzeit",
+		"Time":                       "Zeit",
+		"Top Speed":                  "Höchstgeschwindigkeit",
+		"Fuel Consumed":              "Verbrauch",
+		"Tires Consumed":             "Reifenverschleiß",
+		"Pit Stop":                   "Boxenstopp",
+		"Traction":                   "Traktion",
+		"Lock-ups / Spins":           "Blockieren / Durchdrehen",
+		"Oil / Water / Oil Pressure": "Öl / Wasser / Öldruck",
+		"Tire Temperatures":          "Reifentemperaturen",
+		"Bottoming":                  "Aufsetzen",
+		"Speed Traps":                "Messpunkte",
+		"Incidents":                  "Zwischenfälle",
+		"laps":                       "Runden",
+		"best lap":                   "beste Runde",
+		"in":                         "in",
+		"average":                    "Durchschnitt",
+		"Stints":                     "Stints",
+		"Stint":                      "Stint",
+		"Laps":                       "Runden",
+		"Fuel per Lap":               "Verbrauch pro Runde",
+		"Tire Wear":                  "Reifenverschleiß",
+		"Tire Wear per Lap":          "Reifenverschleiß pro Runde",
+		"Pit Stops":                  "Boxenstopps",
+		"No pit stops":               "Keine Boxenstopps",
+		"Lap":                        "Runde",
+		"lap":                        "Runde",
+		"Consistency":                "Konstanz",
+		"Lap time deviation":         "Abweichung der Rundenzeiten",
+		"degradation":                "Abbau",
+		"most consistent laps":       "konstanteste Runden",
+		"least consistent laps":      "am wenigsten konstante Runden",
+		"Not enough laps":            "Nicht genug Runden",
+		"No incidents":               "Keine Zwischenfälle",
+		"at":                         "bei",
+	},
+}
+
+// Translate returns the text in the language, English texts are the keys
+func Translate(language string, text string) string {
+	if translated, ok := translations[language][text]; ok {
+		return translated
+	}
+	return text
+}
+
+// ParseLanguage checks the language, an empty string selects English
+func ParseLanguage(s string) (string, error) {
+	if s == "" {
+		return LanguageEnglish, nil
+	}
+	for _, language := range Languages {
+		if s == language {
+			return language, nil
+		}
+	}
+	return "", fmt.Errorf("unknown language %q, use one of %v", s, Languages)
+}
+
+type lapTableColumn struct {
+	name    string
+	heading string
+	// cell returns the lines of the cell
+	cell func(lap Lap, traps []SpeedTrap) []string
+}
+
+var lapTableColumns = []lapTableColumn{
+	{"lap", "#", func(lap Lap, traps []SpeedTrap) []string { return []string{fmt.Sprintf("%d", lap.Number)} }},
+	{"race_time", "Race Time", func(lap Lap, traps []SpeedTrap) []string {
+		return []string{GetSportFormat(lap.GetTotalRaceDurationAtEndOfLap())}
+	}},
+	{"time", "Time", func(lap Lap, traps []SpeedTrap) []string { return []string{GetSportFormat(lap.Duration)} }},
+	{"top_speed", "Top Speed", func(lap Lap, traps []SpeedTrap) []string { return []string{fmt.Sprintf("%.0f", lap.GetTopSpeed())} }},
+	{"fuel_consumed", "Fuel Consumed", func(lap Lap, traps []SpeedTrap) []string {
+		return []string{fmt.Sprintf("%.1f%%", lap.GetFuelConsumed())}
+	}},
+	{"tires_consumed", "Tires Consumed", func(lap Lap, traps []SpeedTrap) []string {
+		return []string{lap.TiresStart.Diff(lap.TiresEnd).Format()}
+	}},
+	{"pit_stops", "Pit Stop", func(lap Lap, traps []SpeedTrap) []string { return formatPitStops(lap.PitStops) }},
+	{"traction", "Traction", func(lap Lap, traps []SpeedTrap) []string { return []string{GetTractionSummary(lap).String()} }},
+	{"slip_events", "Lock-ups / Spins", func(lap Lap, traps []SpeedTrap) []string {
+		return []string{formatSlipEvents(GetSlipEvents(lap))}
+	}},
+	{"health", "Oil / Water / Oil Pressure", func(lap Lap, traps []SpeedTrap) []string { return []string{GetLapHealth(lap).String()} }},
+	{"tire_temperatures", "Tire Temperatures", func(lap Lap, traps []SpeedTrap) []string { return []string{lap.TireTemperatures.String()} }},
+	{"bottoming", "Bottoming", func(lap Lap, traps []SpeedTrap) []string {
+		return []string{fmt.Sprintf("%d", len(GetBottomingEvents(lap)))}
+	}},
+	{"speed_traps", "Speed Traps", func(lap Lap, traps []SpeedTrap) []string {
+		return []string{formatTrapSpeeds(getTrapSpeeds(lap, traps))}
+	}},
+	{"incidents", "Incidents", func(lap Lap, traps []SpeedTrap) []string { return formatIncidents(lap.Incidents) }},
+}
+
+// LapTableColumns are the names of the columns of the lap table
+var LapTableColumns = func() []string {
+	names := []string{}
+	for _, c := range lapTableColumns {
+		names = append(names, c.name)
+	}
+	return names
+}()
+
+// ParseLapTableColumns parses columns separated by commas, an empty string selects all columns
+func ParseLapTableColumns(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return LapTableColumns, nil
+	}
+	columns := []string{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if _, err := getLapTableColumn(name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, nil
+}
+
+func getLapTableColumn(name string) (lapTableColumn, error) {
+	for _, c := range lapTableColumns {
+		if c.name == name {
+			return c, nil
+		}
+	}
+	return lapTableColumn{}, fmt.Errorf("unknown column %q, use some of %v", name, LapTableColumns)
+}
+
+type lapTableRow struct {
+	Incident bool
+	Cells    [][]string
+}
+
+type lapTable struct {
+	Headings []string
+	Rows     []lapTableRow
+}
+
+// getHtmlTableForLaps renders the laps with the latest lap first, empty columns selects all columns
+func getHtmlTableForLaps(laps []Lap, traps []SpeedTrap, columns []string, language string) (string, error) {
+	if len(columns) == 0 {
+		columns = LapTableColumns
+	}
+	selected := []lapTableColumn{}
+	table := lapTable{Headings: []string{}, Rows: []lapTableRow{}}
+	for _, name := range columns {
+		c, err := getLapTableColumn(name)
+		if err != nil {
+			return "", err
+		}
+		selected = append(selected, c)
+
+		heading := Translate(language, c.heading)
+		if c.name == "speed_traps" {
+			trapNames := []string{}
+			for _, trap := range traps {
+				trapNames = append(trapNames, trap.Name)
+			}
+			heading = fmt.Sprintf("%s (%s)", heading, strings.Join(trapNames, " / "))
+		}
+		table.Headings = append(table.Headings, heading)
+	}
+
+	for i := len(laps) - 1; i >= 0; i-- {
+		row := lapTableRow{Incident: laps[i].IsIncidentLap()}
+		for _, c := range selected {
+			row.Cells = append(row.Cells, c.cell(laps[i], traps))
+		}
+		table.Rows = append(table.Rows, row)
+	}
+
+	buf := new(bytes.Buffer)
+	err := templates.ExecuteTemplate(buf, "laptable.html", table)
+	if err != nil {
+		return "", fmt.Errorf("error rendering lap table: %v", err)
+	}
+	return buf.String(), nil
+}
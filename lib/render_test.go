@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"bytes"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "Update the golden files in testdata")
+
+// assertGolden compares the output with the golden file, run the tests with -update to rewrite it
+func assertGolden(t *testing.T, name string, actual string) {
+	golden := filepath.Join("testdata", name)
+	if *updateGolden {
+		assert.NoError(t, os.MkdirAll("testdata", 0755))
+		assert.NoError(t, os.WriteFile(golden, []byte(actual), 0644))
+	}
+	expected, err := os.ReadFile(golden)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), actual)
+}
+
+func TestGetHtmlTableForLaps(t *testing.T) {
+	traps := []SpeedTrap{{Name: "Main straight", Distance: 1000}, {Name: "Back <straight>", Distance: 2000}}
+
+	table, err := getHtmlTableForLaps(getRaceLaps(), traps, nil, LanguageEnglish)
+	assert.NoError(t, err)
+	assertGolden(t, "laptable.golden.html", table)
+
+	table, err = getHtmlTableForLaps(getRaceLaps(), traps, []string{"lap", "time", "pit_stops", "speed_traps", "incidents"}, LanguageGerman)
+	assert.NoError(t, err)
+	assertGolden(t, "laptable_de.golden.html", table)
+
+	_, err = getHtmlTableForLaps(getRaceLaps(), traps, []string{"lap", "warp"}, LanguageEnglish)
+	assert.Error(t, err)
+}
+
+func TestGetHtmlTableForLapsEscapes(t *testing.T) {
+	laps := []Lap{{Number: 1, Incidents: []Incident{{Type: "<script>alert(1)</script>"}, {Type: IncidentSpin}}}}
+	table, err := getHtmlTableForLaps(laps, []SpeedTrap{}, []string{"incidents"}, LanguageEnglish)
+	assert.NoError(t, err)
+	assert.NotContains(t, table, "<script>")
+	assert.Contains(t, table, "<td>&lt;script&gt;alert(1)&lt;/script&gt; at 0m<br>spin at 0m</td>")
+}
+
+func TestReportGolden(t *testing.T) {
+	s := NewStats()
+	s.Laps = getRaceLaps()
+	buf := new(bytes.Buffer)
+	assert.NoError(t, s.WriteHTMLReport(buf, "Watkins Glen"))
+	assertGolden(t, "report.golden.html", buf.String())
+
+	s.Settings.Language = LanguageGerman
+	buf.Reset()
+	assert.NoError(t, s.WriteHTMLReport(buf, "Watkins Glen"))
+	assertGolden(t, "report_de.golden.html", buf.String())
+}
+
+func TestParseLapTableColumns(t *testing.T) {
+	columns, err := ParseLapTableColumns("")
+	assert.NoError(t, err)
+	assert.Equal(t, LapTableColumns, columns)
+
+	columns, err = ParseLapTableColumns("lap, time")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"lap", "time"}, columns)
+
+	_, err = ParseLapTableColumns("lap,warp")
+	assert.Error(t, err)
+}
+
+func TestTranslate(t *testing.T) {
+	assert.Equal(t, NoStartDetected, Translate(LanguageEnglish, NoStartDetected))
+	assert.Equal(t, "Noch kein Start erfasst", Translate(LanguageGerman, NoStartDetected))
+	// missing translations stay English
+	assert.Equal(t, "Warp Speed", Translate(LanguageGerman, "Warp Speed"))
+
+	language, err := ParseLanguage("")
+	assert.NoError(t, err)
+	assert.Equal(t, LanguageEnglish, language)
+	_, err = ParseLanguage("fr")
+	assert.Error(t, err)
+}
@@ -0,0 +1,154 @@
+package lib
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+// Stint is a run of laps between pit stops
+type Stint struct {
+	Number   int
+	FirstLap int16
+	LastLap  int16
+	Laps     int
+	// FuelConsumed is the fuel consumed in percent over the stint, refuelling is not counted
+	FuelConsumed float32
+	FuelPerLap   float32
+	// TireWear is the summed wear of the most worn tire in percent, -1 if there is no tire data
+	TireWear       int
+	TireWearPerLap float32
+}
+
+type LapPitStop struct {
+	Lap     int16
+	PitStop PitStop
+}
+
+type LapIncident struct {
+	Lap      int16
+	Incident Incident
+}
+
+type Report struct {
+	Title          string
+	Language       string
+	Laps           int
+	BestLap        Lap
+	AverageLapTime time.Duration
+	LapTable       template.HTML
+	Stints         []Stint
+	PitStops       []LapPitStop
+	LapTimeChart   template.HTML
+	TrackMap       template.HTML
+	Consistency    ConsistencyReport
+	Incidents      []LapIncident
+}
+
+// GetStints splits the laps into stints, a stint ends with the lap into the pit
+func GetStints(laps []Lap) []Stint {
+	stints := []Stint{}
+	var stint *Stint
+	tireLaps := 0
+	for _, lap := range laps {
+		if stint == nil {
+			stint = &Stint{Number: len(stints) + 1, FirstLap: lap.Number, TireWear: -1}
+			tireLaps = 0
+		}
+		stint.LastLap = lap.Number
+		stint.Laps++
+		if lap.GetFuelConsumed() > 0 {
+			stint.FuelConsumed += lap.GetFuelConsumed()
+		}
+		if wear, err := lap.GetTireWear(); err == nil {
+			if stint.TireWear < 0 {
+				stint.TireWear = 0
+			}
+			stint.TireWear += wear
+			tireLaps++
+		}
+
+		if lap.IsLapIntoPit() {
+			stints = append(stints, finishStint(*stint, tireLaps))
+			stint = nil
+		}
+	}
+	if stint != nil {
+		stints = append(stints, finishStint(*stint, tireLaps))
+	}
+	return stints
+}
+
+func finishStint(stint Stint, tireLaps int) Stint {
+	stint.FuelPerLap = stint.FuelConsumed / float32(stint.Laps)
+	if tireLaps > 0 {
+		stint.TireWearPerLap = float32(stint.TireWear) / float32(tireLaps)
+	}
+	return stint
+}
+
+// GetReport collects everything worth sharing after a race
+func (s *Stats) GetReport(title string) (Report, error) {
+	if len(s.Laps) == 0 {
+		return Report{}, fmt.Errorf("no lap driven yet")
+	}
+
+	lapTable, err := getHtmlTableForLaps(s.Laps, s.GetSpeedTraps(), s.Settings.LapTableColumns, s.Settings.Language)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{
+		Title:        title,
+		Language:     s.Settings.Language,
+		Laps:         len(s.Laps),
+		LapTable:     template.HTML(lapTable),
+		Stints:       GetStints(s.Laps),
+		PitStops:     []LapPitStop{},
+		LapTimeChart: template.HTML(DrawLapTimesSVG(s.Laps)),
+		Incidents:    []LapIncident{},
+	}
+
+	// unlike getBestLap laps without recorded data count as well
+	for _, lap := range s.Laps {
+		if lap.IsRegularLap() && (report.BestLap.Number == 0 || lap.Duration < report.BestLap.Duration) {
+			report.BestLap = lap
+		}
+	}
+	outline, err := s.getOutlineLap()
+	if err == nil {
+		report.TrackMap = template.HTML(DrawLapToSVG(outline))
+	}
+	averageLapTime, err := s.GetAverageLapTime()
+	if err == nil {
+		report.AverageLapTime = averageLapTime
+	}
+	consistency, err := s.GetConsistencyReport()
+	if err == nil {
+		report.Consistency = consistency
+	}
+
+	for _, lap := range s.Laps {
+		for _, pitStop := range lap.PitStops {
+			report.PitStops = append(report.PitStops, LapPitStop{Lap: lap.Number, PitStop: pitStop})
+		}
+		for _, incident := range lap.Incidents {
+			report.Incidents = append(report.Incidents, LapIncident{Lap: lap.Number, Incident: incident})
+		}
+	}
+	return report, nil
+}
+
+// WriteHTMLReport writes a self-contained HTML report of the session
+func (s *Stats) WriteHTMLReport(w io.Writer, title string) error {
+	report, err := s.GetReport(title)
+	if err != nil {
+		return err
+	}
+	err = templates.ExecuteTemplate(w, "report.html", report)
+	if err != nil {
+		return fmt.Errorf("error rendering report: %v", err)
+	}
+	return nil
+}
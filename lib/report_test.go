@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"bytes"
+	"github.com/snipem/gt7tools/lib/dump"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func getRaceLaps() []Lap {
+	laps := []Lap{
+		{Number: 1, FuelStart: 100, FuelEnd: 95, Duration: 85 * time.Second},
+		{Number: 2, FuelStart: 95, FuelEnd: 90, Duration: 80 * time.Second},
+		{Number: 3, FuelStart: 90, FuelEnd: 85, Duration: 81 * time.Second, PitStops: []PitStop{{FuelAdded: 15, StationaryTime: 10 * time.Second}}},
+		{Number: 4, FuelStart: 100, FuelEnd: 94, Duration: 90 * time.Second},
+		{Number: 5, FuelStart: 94, FuelEnd: 88, Duration: 82 * time.Second, Incidents: []Incident{{Type: IncidentSpin, Start: 1200}}},
+	}
+	for i := 1; i < len(laps); i++ {
+		laps[i].PreviousLap = &laps[i-1]
+	}
+	return laps
+}
+
+func TestGetStints(t *testing.T) {
+	stints := GetStints(getRaceLaps())
+	assert.Len(t, stints, 2)
+
+	assert.Equal(t, int16(1), stints[0].FirstLap)
+	assert.Equal(t, int16(3), stints[0].LastLap)
+	assert.Equal(t, 3, stints[0].Laps)
+	assert.Equal(t, float32(15), stints[0].FuelConsumed)
+	assert.Equal(t, float32(5), stints[0].FuelPerLap)
+	assert.Equal(t, -1, stints[0].TireWear)
+
+	assert.Equal(t, 2, stints[1].Number)
+	assert.Equal(t, int16(4), stints[1].FirstLap)
+	assert.Equal(t, float32(6), stints[1].FuelPerLap)
+}
+
+func TestStats_WriteHTMLReport(t *testing.T) {
+	s := NewStats()
+	buf := new(bytes.Buffer)
+	assert.Error(t, s.WriteHTMLReport(buf, "Empty"))
+
+	s.Laps = getRaceLaps()
+	assert.NoError(t, s.WriteHTMLReport(buf, "Watkins Glen <Race>"))
+	report := buf.String()
+	assert.Contains(t, report, "<title>Watkins Glen &lt;Race&gt;</title>")
+	assert.Contains(t, report, "<p>5 laps, best lap 2")
+	assert.Contains(t, report, "<table class='laptable'>")
+	assert.Contains(t, report, "<td>4 - 5</td>")
+	assert.Contains(t, report, "Lap 3: 10.0s stationary")
+	assert.Contains(t, report, "Lap 5: spin at 1200m")
+	assert.Contains(t, report, "<svg")
+	// only lap 2 is a regular lap without incident
+	assert.Contains(t, report, "<h2>Consistency</h2>\n<p>Not enough laps</p>")
+}
+
+func TestStats_WriteHTMLReportOfReplayedSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.gob.gz")
+	assert.NoError(t, dump.WriteGT7Data(path, getPitStopSession()))
+	s, err := LoadSession(path)
+	assert.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, s.WriteHTMLReport(buf, "Replay"))
+	// the stationary time of the pit stop comes from the package IDs of the replayed session
+	assert.Contains(t, buf.String(), "Lap 1: 4.8s stationary")
+}
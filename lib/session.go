@@ -0,0 +1,50 @@
+package lib
+
+import (
+	"fmt"
+	"github.com/jmhodges/clock"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/snipem/gt7tools/lib/dump"
+	"path/filepath"
+)
+
+// liveSamplePackages is the number of packages between two logged packages live, where the
+// telemetry is logged every 100ms while GT7 sends a package every 16ms
+const liveSamplePackages = 6
+
+// LoadSession replays the dump file of a stored session and returns the stats of it
+func LoadSession(path string) (*Stats, error) {
+	data, err := dump.ReadGT7Data(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading session %s: %v", path, err)
+	}
+
+	s := NewStats()
+	// the session is replayed faster than real time, so the clock follows the package IDs instead
+	fakeClock := clock.NewFake()
+	s.setClock(fakeClock)
+	raceTimeInMinutes := 0
+	var logged *gt7.GTData
+	for i := range data {
+		if i > 0 && data[i].PackageID > data[i-1].PackageID {
+			fakeClock.Add(packageNumbersToDuration(data[i].PackageID - data[i-1].PackageID))
+		}
+		// only every few packages are logged like live, so that replays and live give the same results
+		if logged != nil && data[i].PackageID-logged.PackageID < liveSamplePackages && data[i].PackageID >= logged.PackageID &&
+			data[i].CurrentLap == logged.CurrentLap {
+			continue
+		}
+		LogTick(&data[i], s, &raceTimeInMinutes)
+		logged = &data[i]
+	}
+	return s, nil
+}
+
+// GetSessionPath returns the path of the stored session in the session dir, the name
+// must not leave the session dir
+func GetSessionPath(sessionDir string, name string) (string, error) {
+	if name == "" || filepath.Base(name) != name || name == ".." {
+		return "", fmt.Errorf("invalid session name %q", name)
+	}
+	return filepath.Join(sessionDir, name), nil
+}
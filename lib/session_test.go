@@ -0,0 +1,84 @@
+package lib
+
+import (
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/snipem/gt7tools/lib/dump"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSession(t *testing.T) {
+	data := []gt7.GTData{}
+	packageID := int32(0)
+	for lap := int16(0); lap <= 3; lap++ {
+		for i := 0; i < 100; i++ {
+			packageID++
+			data = append(data, gt7.GTData{
+				PackageID:   packageID,
+				CurrentLap:  lap,
+				CarSpeed:    100,
+				CurrentFuel: 100 - float32(packageID)/100,
+				LastLap:     60000,
+			})
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "session.gob.gz")
+	assert.NoError(t, dump.WriteGT7Data(path, data))
+
+	s, err := LoadSession(path)
+	assert.NoError(t, err)
+	assert.Len(t, s.Laps, 2)
+	assert.Equal(t, int16(1), s.Laps[0].Number)
+	// only every sixth package is logged like live
+	assert.Len(t, s.Laps[1].DataHistory, 17)
+
+	_, err = LoadSession(filepath.Join(t.TempDir(), "missing.gob.gz"))
+	assert.Error(t, err)
+}
+
+// getPitStopSession has a pit stop in lap 1 where the car stands for 300 packages while it is refuelled
+func getPitStopSession() []gt7.GTData {
+	data := []gt7.GTData{}
+	fuel := float32(50)
+	for packageID := int32(1); packageID <= 1000; packageID++ {
+		d := gt7.GTData{
+			PackageID:   packageID,
+			CurrentLap:  int16(packageID / 400),
+			CarSpeed:    100,
+			CurrentFuel: fuel,
+			LastLap:     60000,
+		}
+		if packageID > 300 && packageID <= 600 {
+			d.CarSpeed = 0
+			fuel += 0.1
+			d.CurrentFuel = fuel
+		}
+		data = append(data, d)
+	}
+	return data
+}
+
+func TestLoadSessionPitStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.gob.gz")
+	assert.NoError(t, dump.WriteGT7Data(path, getPitStopSession()))
+
+	s, err := LoadSession(path)
+	assert.NoError(t, err)
+	assert.Len(t, s.Laps[0].PitStops, 1)
+	// the clock follows the package IDs, 300 packages are 4.8s
+	assert.InDelta(t, 4.8, s.Laps[0].PitStops[0].StationaryTime.Seconds(), 0.1)
+	assert.InDelta(t, 30, s.Laps[0].PitStops[0].FuelAdded, 1)
+}
+
+func TestGetSessionPath(t *testing.T) {
+	path, err := GetSessionPath("sessions", "race.gob.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("sessions", "race.gob.gz"), path)
+
+	for _, name := range []string{"", "..", "../secret", "a/b"} {
+		_, err := GetSessionPath("sessions", name)
+		assert.Error(t, err, name)
+	}
+}
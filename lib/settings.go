@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+)
+
+// multiplierTolerance is the relative difference between observed and expected fuel consumption
+// that is accepted before the configured fuel multiplier is flagged
+const multiplierTolerance = 0.25
+
+// RaceSettings are the lobby settings and preferences of the driver that are not transmitted over telemetry
+type RaceSettings struct {
+	FuelMultiplier float32
+	TireMultiplier float32
+	// TireTempWindow is the optimal temperature window of the tires
+	TireTempWindow TemperatureWindow
+	// SpeedTraps are the speed traps of the track, empty to place them automatically
+	SpeedTraps []SpeedTrap
+	// LapTableColumns are the columns of the lap table, empty for all columns
+	LapTableColumns []string
+	// Language of the dashboard and the report, one of Languages
+	Language string
+}
+
+func NewRaceSettings() RaceSettings {
+	return RaceSettings{
+		FuelMultiplier: 1,
+		TireMultiplier: 1,
+		TireTempWindow: NewTemperatureWindow(),
+		Language:       LanguageEnglish,
+	}
+}
+
+// getMultiplier returns the multiplier or 1 if it is not set, e.g. for laps stored before multipliers were recorded
+func getMultiplier(multiplier float32) float32 {
+	if multiplier <= 0 {
+		return 1
+	}
+	return multiplier
+}
+
+// GetFuelBaseline returns the fuel consumption per lap of the car on the track with a fuel multiplier of 1.
+// Only previous sessions count, laps driven with a wrong multiplier would pull the baseline towards it
+func (s *Stats) GetFuelBaseline() (float32, error) {
+	if s.Store == nil {
+		return -1, fmt.Errorf("no store available")
+	}
+
+	storedLaps, err := s.getStoredLapsOfPreviousSessions()
+	if err != nil {
+		return -1, err
+	}
+
+	baseline, err := getFuelPriorFromStoredLaps(storedLaps, 1)
+	if err != nil {
+		return -1, err
+	}
+	return baseline.ConsumptionPerLap, nil
+}
+
+// CheckFuelMultiplier compares the observed fuel consumption with the one expected from the
+// baseline of the car and the configured fuel multiplier
+func (s *Stats) CheckFuelMultiplier() error {
+	baseline, err := s.GetFuelBaseline()
+	if err != nil {
+		// nothing to compare with
+		return nil
+	}
+
+	observed, err := s.GetAverageFuelConsumptionPerLap()
+	if err != nil {
+		return nil
+	}
+
+	return checkFuelMultiplier(observed, baseline, s.Settings.FuelMultiplier)
+}
+
+func checkFuelMultiplier(observed float32, baseline float32, fuelMultiplier float32) error {
+	if baseline <= 0 {
+		return nil
+	}
+
+	expected := baseline * getMultiplier(fuelMultiplier)
+	if math.Abs(float64(observed-expected)) > multiplierTolerance*float64(expected) {
+		return fmt.Errorf("observed fuel consumption of %.2f per lap does not match fuel multiplier %.1f, expected %.2f which looks like fuel multiplier %.1f",
+			observed, getMultiplier(fuelMultiplier), expected, observed/baseline)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_checkFuelMultiplier(t *testing.T) {
+	assert.NoError(t, checkFuelMultiplier(4, 2, 2))
+	assert.NoError(t, checkFuelMultiplier(4.5, 2, 2), "within tolerance")
+	assert.Error(t, checkFuelMultiplier(6, 2, 2), "looks like multiplier 3")
+	assert.Error(t, checkFuelMultiplier(2, 2, 2), "looks like multiplier 1")
+	assert.NoError(t, checkFuelMultiplier(2, 2, 0), "unset multiplier is 1")
+	assert.NoError(t, checkFuelMultiplier(2, 0, 2), "no baseline")
+}
+
+func TestStats_CheckFuelMultiplier(t *testing.T) {
+	s := NewStats()
+	assert.NoError(t, s.CheckFuelMultiplier(), "no store, nothing to compare")
+
+	store, err := NewStore(t.TempDir())
+	assert.NoError(t, err)
+	s.Store = store
+	assert.NoError(t, store.AddLap(StoredLap{Number: 2, FuelConsumed: 4, FuelMultiplier: 2}))
+
+	baseline, err := s.GetFuelBaseline()
+	assert.NoError(t, err)
+	assert.Equal(t, float32(2), baseline)
+
+	s.Settings.FuelMultiplier = 3
+	s.Laps = []Lap{{Number: 1, FuelStart: 100, FuelEnd: 94}}
+	assert.NoError(t, s.CheckFuelMultiplier())
+
+	s.Settings.FuelMultiplier = 1
+	assert.Error(t, s.CheckFuelMultiplier())
+}
+
+func TestStats_CheckFuelMultiplierIgnoresCurrentSession(t *testing.T) {
+	s := NewStats()
+	store, err := NewStore(t.TempDir())
+	assert.NoError(t, err)
+	s.Store = store
+	assert.NoError(t, store.AddLap(StoredLap{Number: 2, FuelConsumed: 4, FuelMultiplier: 2}))
+
+	// the lobby has fuel multiplier 3 but 1 is set, the laps of this session are stored with the wrong multiplier
+	s.Settings.FuelMultiplier = 1
+	for i := int16(1); i <= 5; i++ {
+		lap := Lap{Number: i, FuelStart: 100 - float32(i-1)*6, FuelEnd: 100 - float32(i)*6}
+		s.Laps = append(s.Laps, lap)
+		assert.NoError(t, store.AddLap(newStoredLap(0, "", s.sessionID, lap, s.Settings, time.Now())))
+	}
+
+	baseline, err := s.GetFuelBaseline()
+	assert.NoError(t, err)
+	assert.Equal(t, float32(2), baseline)
+	assert.Error(t, s.CheckFuelMultiplier())
+}
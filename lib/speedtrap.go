@@ -0,0 +1,216 @@
+package lib
+
+import (
+	"fmt"
+	"github.com/montanaflynn/stats"
+	"strconv"
+	"strings"
+)
+
+// heavyBrakingPressure is the peak brake pressure in percent of braking zones that get an automatic speed trap
+const heavyBrakingPressure = 80
+
+// speedTrapLeadDistance is the distance in meters before the brake point an automatic speed trap is placed at
+const speedTrapLeadDistance = 10
+
+// slipstreamSpeedGain is the speed in km/h above the median of a trap that counts as a slipstream lap
+const slipstreamSpeedGain = 5
+
+// powerLossSlope is the trend in km/h per lap below which a trap indicates a loss of engine power
+const powerLossSlope = -0.5
+
+// minTrendLaps is the number of laps needed to compute a trend
+const minTrendLaps = 3
+
+type SpeedTrap struct {
+	Name string `json:"name"`
+	// Distance is the position of the trap as distance from the start of the lap in meters
+	Distance float32 `json:"distance"`
+}
+
+type CornerSpeed struct {
+	Corner int     `json:"corner"`
+	Speed  float32 `json:"speed"`
+}
+
+type LapSpeeds struct {
+	Lap int16 `json:"lap"`
+	// TrapSpeeds are the speeds in the order of the traps, -1 if the lap did not reach the trap
+	TrapSpeeds      []float32     `json:"trap_speeds"`
+	MinCornerSpeeds []CornerSpeed `json:"min_corner_speeds"`
+}
+
+type TrapTrend struct {
+	Name string `json:"name"`
+	// Slope is the change of the trap speed in km/h per lap over the regular laps
+	Slope          float32 `json:"slope"`
+	SlipstreamLaps []int16 `json:"slipstream_laps"`
+	PowerLoss      bool    `json:"power_loss"`
+}
+
+type SpeedTrapReport struct {
+	Traps  []SpeedTrap `json:"traps"`
+	Laps   []LapSpeeds `json:"laps"`
+	Trends []TrapTrend `json:"trends"`
+}
+
+// ParseSpeedTraps parses speed traps in the format "name:distance,name:distance"
+func ParseSpeedTraps(s string) ([]SpeedTrap, error) {
+	traps := []SpeedTrap{}
+	if s == "" {
+		return traps, nil
+	}
+	for _, trap := range strings.Split(s, ",") {
+		parts := strings.Split(trap, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("speed trap %q is not in the format name:distance", trap)
+		}
+		distance, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 32)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing distance of speed trap %q: %v", trap, err)
+		}
+		traps = append(traps, SpeedTrap{Name: strings.TrimSpace(parts[0]), Distance: float32(distance)})
+	}
+	return traps, nil
+}
+
+// getSpeedAtDistance returns the speed of the first package at or after the distance
+func getSpeedAtDistance(lap Lap, distances []float32, distance float32) float32 {
+	for i, d := range distances {
+		if d >= distance {
+			return lap.DataHistory[i].CarSpeed
+		}
+	}
+	return -1
+}
+
+// getTrapSpeeds returns the speed at every trap, -1 if the lap did not reach the trap
+func getTrapSpeeds(lap Lap, traps []SpeedTrap) []float32 {
+	speeds := []float32{}
+	distances := lap.GetDistances()
+	for _, trap := range traps {
+		speeds = append(speeds, getSpeedAtDistance(lap, distances, trap.Distance))
+	}
+	return speeds
+}
+
+// GetSpeedTraps returns the configured speed traps, without configuration a trap is placed before
+// every heavy braking zone of the best lap
+func (s *Stats) GetSpeedTraps() []SpeedTrap {
+	if len(s.Settings.SpeedTraps) > 0 {
+		return s.Settings.SpeedTraps
+	}
+
+	traps := []SpeedTrap{}
+	bestLap, err := s.GetBestLap()
+	if err != nil {
+		return traps
+	}
+	for _, zone := range GetBrakingZones(bestLap) {
+		if zone.PeakPressure < heavyBrakingPressure {
+			continue
+		}
+		distance := zone.Start - speedTrapLeadDistance
+		if distance < 0 {
+			distance = 0
+		}
+		traps = append(traps, SpeedTrap{Name: fmt.Sprintf("before corner %d", zone.Corner), Distance: distance})
+	}
+	return traps
+}
+
+// GetLapSpeeds records the speed at every trap and the minimum speed of every corner, corners are
+// numbered like the braking zones of the best lap
+func GetLapSpeeds(lap Lap, traps []SpeedTrap, bestZones []BrakingZone) LapSpeeds {
+	speeds := LapSpeeds{Lap: lap.Number, TrapSpeeds: getTrapSpeeds(lap, traps), MinCornerSpeeds: []CornerSpeed{}}
+	for _, zone := range GetBrakingZones(lap) {
+		best, err := matchBrakingZone(zone, bestZones)
+		if err != nil {
+			continue
+		}
+		speeds.MinCornerSpeeds = append(speeds.MinCornerSpeeds, CornerSpeed{Corner: best.Corner, Speed: zone.MinSpeed})
+	}
+	return speeds
+}
+
+func formatTrapSpeeds(speeds []float32) string {
+	formatted := []string{}
+	for _, speed := range speeds {
+		if speed < 0 {
+			formatted = append(formatted, "-")
+			continue
+		}
+		formatted = append(formatted, fmt.Sprintf("%.0f", speed))
+	}
+	return strings.Join(formatted, " / ")
+}
+
+// getTrapTrend finds slipstream laps and the trend of the speed at a trap over the regular laps
+func getTrapTrend(trap SpeedTrap, laps []Lap, speeds []float32) TrapTrend {
+	trend := TrapTrend{Name: trap.Name, SlipstreamLaps: []int16{}}
+
+	lapNumbers, regularSpeeds := []float64{}, stats.Float64Data{}
+	for i, lap := range laps {
+		if !lap.IsRegularLap() || speeds[i] < 0 {
+			continue
+		}
+		lapNumbers = append(lapNumbers, float64(lap.Number))
+		regularSpeeds = append(regularSpeeds, float64(speeds[i]))
+	}
+	if len(regularSpeeds) < minTrendLaps {
+		return trend
+	}
+
+	median, err := regularSpeeds.Median()
+	if err != nil {
+		return trend
+	}
+	// slipstream laps would hide a loss of power, so they are left out of the trend
+	trendLapNumbers, trendSpeeds := []float64{}, []float64{}
+	for i, speed := range regularSpeeds {
+		if speed > median+slipstreamSpeedGain {
+			trend.SlipstreamLaps = append(trend.SlipstreamLaps, int16(lapNumbers[i]))
+			continue
+		}
+		trendLapNumbers = append(trendLapNumbers, lapNumbers[i])
+		trendSpeeds = append(trendSpeeds, speed)
+	}
+
+	if len(trendSpeeds) < minTrendLaps {
+		return trend
+	}
+	slope, err := getSlope(trendLapNumbers, trendSpeeds)
+	if err != nil {
+		return trend
+	}
+	trend.Slope = float32(slope)
+	trend.PowerLoss = slope < powerLossSlope
+	return trend
+}
+
+// GetSpeedTrapReport records the trap and corner speeds of every lap and the trend of every trap
+func (s *Stats) GetSpeedTrapReport() (SpeedTrapReport, error) {
+	report := SpeedTrapReport{Traps: s.GetSpeedTraps(), Laps: []LapSpeeds{}, Trends: []TrapTrend{}}
+	if len(s.Laps) == 0 {
+		return report, fmt.Errorf("no lap driven yet")
+	}
+
+	bestZones := []BrakingZone{}
+	bestLap, err := s.GetBestLap()
+	if err == nil {
+		bestZones = GetBrakingZones(bestLap)
+	}
+
+	for _, lap := range s.Laps {
+		report.Laps = append(report.Laps, GetLapSpeeds(lap, report.Traps, bestZones))
+	}
+
+	for t, trap := range report.Traps {
+		speeds := []float32{}
+		for _, lapSpeeds := range report.Laps {
+			speeds = append(speeds, lapSpeeds.TrapSpeeds[t])
+		}
+		report.Trends = append(report.Trends, getTrapTrend(trap, s.Laps, speeds))
+	}
+	return report, nil
+}
@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// getLapWithTrapSpeed brakes at package 500 like getLapWithBrakingZones and drives the first 400 packages with trapSpeed
+func getLapWithTrapSpeed(number int16, trapSpeed float32) Lap {
+	lap := getLapWithBrakingZones(time.Minute+time.Duration(number)*time.Second, []int32{500}, 40)
+	lap.Number = number
+	for i := 0; i < 400; i++ {
+		lap.DataHistory[i].CarSpeed = trapSpeed
+	}
+	return lap
+}
+
+func TestParseSpeedTraps(t *testing.T) {
+	traps, err := ParseSpeedTraps("Main straight:1200, T1: 300.5")
+	assert.NoError(t, err)
+	assert.Equal(t, []SpeedTrap{{Name: "Main straight", Distance: 1200}, {Name: "T1", Distance: 300.5}}, traps)
+
+	traps, err = ParseSpeedTraps("")
+	assert.NoError(t, err)
+	assert.Len(t, traps, 0)
+
+	_, err = ParseSpeedTraps("Main straight")
+	assert.Error(t, err)
+	_, err = ParseSpeedTraps("Main straight:far")
+	assert.Error(t, err)
+}
+
+func TestGetLapSpeeds(t *testing.T) {
+	lap := getLapWithTrapSpeed(2, 200)
+	speeds := GetLapSpeeds(lap, []SpeedTrap{{Name: "Straight", Distance: 100}, {Name: "Beyond", Distance: 100000}}, GetBrakingZones(lap))
+	assert.Equal(t, []float32{200, -1}, speeds.TrapSpeeds)
+	assert.Equal(t, []CornerSpeed{{Corner: 1, Speed: 80}}, speeds.MinCornerSpeeds)
+	assert.Equal(t, "200 / -", formatTrapSpeeds(speeds.TrapSpeeds))
+}
+
+func TestStats_GetSpeedTraps(t *testing.T) {
+	s := NewStats()
+	assert.Len(t, s.GetSpeedTraps(), 0)
+
+	s.Laps = []Lap{getLapWithTrapSpeed(2, 180)}
+	traps := s.GetSpeedTraps()
+	assert.Len(t, traps, 1)
+	assert.Equal(t, "before corner 1", traps[0].Name)
+	assert.InDelta(t, 390, traps[0].Distance, 1)
+
+	s.Settings.SpeedTraps = []SpeedTrap{{Name: "Main straight", Distance: 100}}
+	assert.Equal(t, s.Settings.SpeedTraps, s.GetSpeedTraps())
+}
+
+func TestStats_GetSpeedTrapReport(t *testing.T) {
+	s := NewStats()
+	_, err := s.GetSpeedTrapReport()
+	assert.Error(t, err)
+
+	s.Settings.SpeedTraps = []SpeedTrap{{Name: "Main straight", Distance: 100}}
+
+	t.Run("Power loss", func(t *testing.T) {
+		s.Laps = []Lap{}
+		for lap := int16(1); lap <= 6; lap++ {
+			s.Laps = append(s.Laps, getLapWithTrapSpeed(lap, 200-float32(lap)))
+		}
+		report, err := s.GetSpeedTrapReport()
+		assert.NoError(t, err)
+		assert.Len(t, report.Laps, 6)
+		assert.Equal(t, []float32{199}, report.Laps[0].TrapSpeeds)
+		assert.InDelta(t, -1, report.Trends[0].Slope, 0.001)
+		assert.True(t, report.Trends[0].PowerLoss)
+		assert.Len(t, report.Trends[0].SlipstreamLaps, 0)
+	})
+
+	t.Run("Slipstream", func(t *testing.T) {
+		s.Laps = []Lap{}
+		for lap := int16(1); lap <= 6; lap++ {
+			s.Laps = append(s.Laps, getLapWithTrapSpeed(lap, 200))
+		}
+		s.Laps[3] = getLapWithTrapSpeed(4, 210)
+		report, err := s.GetSpeedTrapReport()
+		assert.NoError(t, err)
+		assert.Equal(t, []int16{4}, report.Trends[0].SlipstreamLaps)
+		assert.Equal(t, float32(0), report.Trends[0].Slope)
+		assert.False(t, report.Trends[0].PowerLoss)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/montanaflynn/stats"
 	gt7 "github.com/snipem/go-gt7-telemetry/lib"
 	"github.com/snipem/gt7fuel/lib/experimental"
+	"log"
 	"math"
 	"strings"
 	"time"
@@ -17,6 +18,7 @@ type History struct {
 	CarSpeed          []int
 	PackageId         []int32
 	TravelledDistance []float32
+	Fuel              []float32
 }
 
 func (h *History) Update(data gt7.GTData) {
@@ -31,12 +33,13 @@ func (h *History) Update(data gt7.GTData) {
 	h.Throttle = append(h.Throttle, int(data.Throttle))
 	h.Brake = append(h.Brake, int(data.Brake))
 	h.CarSpeed = append(h.CarSpeed, int(data.CarSpeed))
+	h.Fuel = append(h.Fuel, data.CurrentFuel)
 
 	if len(h.TravelledDistance) > 0 {
 		packageDuration := packageNumbersToDuration(data.PackageID - lastPackageId)
 		h.TravelledDistance = append(h.TravelledDistance,
-			h.TravelledDistance[len(h.TravelledDistance)-1],
-			getTravelledDistanceInMeters(data.CarSpeed, packageDuration))
+			h.TravelledDistance[len(h.TravelledDistance)-1]+
+				getTravelledDistanceInMeters(data.CarSpeed, packageDuration))
 	} else {
 		h.TravelledDistance = append(h.TravelledDistance, float32(0))
 	}
@@ -44,6 +47,20 @@ func (h *History) Update(data gt7.GTData) {
 	//fmt.Printf("%f m\n", h.TravelledDistance[len(h.TravelledDistance)-1])
 }
 
+// GetTravelledDistanceSince returns the distance in meters travelled since the given package
+func (h *History) GetTravelledDistanceSince(packageId int32) (float32, error) {
+	if len(h.TravelledDistance) == 0 {
+		return 0, fmt.Errorf("no travelled distance recorded yet")
+	}
+
+	for i := len(h.PackageId) - 1; i >= 0; i-- {
+		if h.PackageId[i] == packageId {
+			return h.TravelledDistance[len(h.TravelledDistance)-1] - h.TravelledDistance[i], nil
+		}
+	}
+	return 0, fmt.Errorf("package %d not found in history", packageId)
+}
+
 func getTravelledDistanceInMeters(carSpeed float32, duration time.Duration) float32 {
 
 	var distancePerHourTravelledInMeters float32
@@ -61,6 +78,15 @@ func packageNumbersToDuration(i int32) time.Duration {
 	return time.Duration(i*16) * time.Millisecond
 }
 
+// getSampleDuration returns the time since the sample before. Live only about every sixth package
+// is stored, so the samples are further apart than one package
+func getSampleDuration(history []gt7.GTData, i int) time.Duration {
+	if i == 0 || history[i].PackageID <= history[i-1].PackageID {
+		return packageNumbersToDuration(1)
+	}
+	return packageNumbersToDuration(history[i].PackageID - history[i-1].PackageID)
+}
+
 func (h *History) IsTrailBreakingIncreasing() bool {
 	return len(h.Brake) > 1 && h.Brake[len(h.Brake)-1] > h.Brake[len(h.Brake)-2] &&
 		!straightIncreaseFromZeroBraking(h.Brake)
@@ -74,18 +100,35 @@ type Stats struct {
 	LastTireData   *experimental.TireData
 	// ManualSetRaceDuration is the race duration manually set by the user if it is not
 	// transmitted over telemetry
-	ManualSetRaceDuration    time.Duration
-	raceStartTime            time.Time
+	ManualSetRaceDuration time.Duration
+	raceStartTime         time.Time
+	// sessionID tells the laps of this session apart from laps of previous sessions in the store
+	sessionID                string
 	clock                    clock.Clock
 	ConnectionActive         bool
 	History                  *History
 	ShallRun                 bool
 	HeavyMessageNeedsRefresh bool
 	DataHistory              []gt7.GTData
+	pitDetector              pitDetector
+	slipDetector             slipDetector
+	healthMonitor            healthMonitor
+	// FuelPrior is the user entered fuel consumption, it has precedence over the Store
+	FuelPrior *FuelPrior
+	// Store keeps laps of previous races, nil if laps should not be stored
+	Store *Store
+	// Settings are the lobby settings entered by the user
+	Settings RaceSettings
+	// Averaging selects how the average fuel consumption is calculated
+	Averaging AveragingSettings
+	// Track is the user given name of the track, since it is not transmitted over telemetry
+	Track string
+	// IncludeIncidentLaps includes laps with incidents in averages and the lap time deviation
+	IncludeIncidentLaps bool
 }
 
 func (s *Stats) GetLapTimeDeviation() (duration time.Duration, err error) {
-	return getLapTimeDeviation(s.Laps)
+	return getLapTimeDeviation(s.getLapsForAverages())
 }
 
 func getLapTimeDeviation(laps []Lap) (time.Duration, error) {
@@ -111,6 +154,47 @@ func getLapTimeDeviation(laps []Lap) (time.Duration, error) {
 
 }
 
+// getBestLap returns the fastest regular lap with recorded data
+func getBestLap(laps []Lap) (Lap, error) {
+	bestLap := Lap{}
+	for _, lap := range laps {
+		if !lap.IsRegularLap() || len(lap.DataHistory) == 0 {
+			continue
+		}
+		if bestLap.Duration == 0 || lap.Duration < bestLap.Duration {
+			bestLap = lap
+		}
+	}
+	if bestLap.Duration == 0 {
+		return Lap{}, fmt.Errorf("no regular lap with data found, nr of laps: %d", len(laps))
+	}
+	return bestLap, nil
+}
+
+// GetBestLap returns the fastest regular lap with recorded data of the session. It is the reference for
+// the racing line and the comparisons, so incident laps are left out unless they should be included
+func (s *Stats) GetBestLap() (Lap, error) {
+	return getBestLap(s.getLapsForAverages())
+}
+
+// GetLastLap returns the last finished lap of the session
+func (s *Stats) GetLastLap() (Lap, error) {
+	if len(s.Laps) == 0 {
+		return Lap{}, fmt.Errorf("no lap driven yet")
+	}
+	return s.Laps[len(s.Laps)-1], nil
+}
+
+// GetLap returns the lap with the given number
+func GetLap(laps []Lap, number int16) (Lap, error) {
+	for _, lap := range laps {
+		if lap.Number == number {
+			return lap, nil
+		}
+	}
+	return Lap{}, fmt.Errorf("lap %d not found, nr of laps: %d", number, len(laps))
+}
+
 func (s *Stats) GetFuelConsumptionLastLap() (float32, error) {
 	return getFuelConsumptionLastLap(s.Laps)
 }
@@ -139,8 +223,11 @@ func NewStats() *Stats {
 	s.ConnectionActive = false
 	// set a proper clock
 	s.setClock(clock.New())
+	s.sessionID = s.clock.Now().Format(time.RFC3339Nano)
 	s.ShallRun = true
 	s.HeavyMessageNeedsRefresh = false
+	s.Averaging = NewAveragingSettings()
+	s.Settings = NewRaceSettings()
 	return &s
 }
 
@@ -155,6 +242,13 @@ type Lap struct {
 	TiresEnd     experimental.TireData
 	TiresStart   experimental.TireData
 	DataHistory  []gt7.GTData
+	PitStops     []PitStop
+	// TireTemperatures are set when the lap is finished
+	TireTemperatures TireTemperatures
+	// Incidents are detected when the lap is finished
+	Incidents []Incident
+	// brakingZones are segmented when the lap is finished, so the analyses do not segment the lap again
+	brakingZones []BrakingZone
 }
 
 func (l Lap) String() string {
@@ -203,7 +297,7 @@ func (l Lap) IsOutLapFromPit() bool {
 }
 
 func (l Lap) IsLapIntoPit() bool {
-	return l.GetFuelConsumed() < 0
+	return len(l.PitStops) > 0 || l.GetFuelConsumed() < 0
 }
 
 func (l Lap) GetTopSpeed() float32 {
@@ -218,26 +312,53 @@ func (l Lap) GetTopSpeed() float32 {
 	return topSpeed
 }
 
+// GetDistance returns the distance in meters driven in this lap
+func (l Lap) GetDistance() float32 {
+	distances := l.GetDistances()
+	if len(distances) == 0 {
+		return 0
+	}
+	return distances[len(distances)-1]
+}
+
+// GetDistances returns the distance in meters from the start of the lap for every package of the lap
+func (l Lap) GetDistances() []float32 {
+	distances := make([]float32, len(l.DataHistory))
+	for i := 1; i < len(l.DataHistory); i++ {
+		packageDuration := packageNumbersToDuration(l.DataHistory[i].PackageID - l.DataHistory[i-1].PackageID)
+		distances[i] = distances[i-1] + getTravelledDistanceInMeters(l.DataHistory[i].CarSpeed, packageDuration)
+	}
+	return distances
+}
+
+// GetTireWear returns the wear of the most worn tire in percent, it is unknown without tire data
+func (l Lap) GetTireWear() (int, error) {
+	if l.TiresStart.LastWrite.IsZero() || l.TiresEnd.LastWrite.IsZero() {
+		return -1, fmt.Errorf("no tire data for lap %d", l.Number)
+	}
+	diff := l.TiresStart.Diff(l.TiresEnd)
+	wear := diff.FrontLeft
+	for _, w := range []int{diff.FrontRight, diff.RearLeft, diff.RearRight} {
+		if w > wear {
+			wear = w
+		}
+	}
+	return wear, nil
+}
+
 func (s *Stats) Reset() {
 	s.LastLoggedData = gt7.GTData{}
 	s.LastData = &gt7.GTData{}
+	s.healthMonitor = healthMonitor{}
 
 	// Set empty ongoing lap
 	s.raceStartTime = s.clock.Now()
 }
-func (s *Stats) GetAverageFuelConsumptionPerLap() (avgFuelConsumption float32, err error) {
-	var totalFuelConsumption float32
-	lapsAccountable := GetAccountableFuelConsumption(s.Laps)
 
-	for _, f := range lapsAccountable {
-		totalFuelConsumption += f
-	}
-
-	if len(lapsAccountable) == 0 {
-		return -1, fmt.Errorf("no accountable laps found")
-	}
-
-	return totalFuelConsumption / float32(len(lapsAccountable)), err
+// GetAverageFuelConsumptionPerLap averages the fuel consumption of the accountable laps with the selected averaging method
+func (s *Stats) GetAverageFuelConsumptionPerLap() (avgFuelConsumption float32, err error) {
+	avgFuelConsumption, _, err = getAverageFuelConsumption(s.getLapsForAverages(), s.Averaging)
+	return avgFuelConsumption, err
 }
 
 func (s *Stats) GetFuelConsumptionPerMinute() (float32, error) {
@@ -256,7 +377,7 @@ func (s *Stats) GetFuelConsumptionPerMinute() (float32, error) {
 
 func (s *Stats) GetAverageLapTime() (time.Duration, error) {
 	var totalDuration time.Duration
-	accountableLaps := getAccountableLaps(s.Laps)
+	accountableLaps := getAccountableLaps(s.getLapsForAverages())
 	for _, lap := range accountableLaps {
 		totalDuration += lap.Duration
 	}
@@ -268,20 +389,70 @@ func (s *Stats) GetAverageLapTime() (time.Duration, error) {
 	return totalDuration / time.Duration(len(accountableLaps)), nil
 }
 
-const NoStartDetected = "Noch kein Start erfasst"
+const NoStartDetected = "No start detected yet"
 
 func (s *Stats) GetHeavyMessage() HeavyMessage {
 
-	formattedLaps := getHtmlTableForLaps(s.Laps)
+	formattedLaps, err := getHtmlTableForLaps(s.Laps, s.GetSpeedTraps(), s.Settings.LapTableColumns, s.Settings.Language)
+	if err != nil {
+		log.Printf("No lap table: %v\n", err)
+	}
 
 	lapToDraw := Lap{}
 	if len(s.Laps) > 0 {
 		lapToDraw = s.Laps[len(s.Laps)-1]
 	}
 
+	brakingFeedback, err := s.GetBrakingFeedback()
+	if err != nil {
+		log.Printf("No braking feedback: %v\n", err)
+	}
+
+	traction, err := s.GetTractionAnalysis()
+	if err != nil {
+		log.Printf("No traction analysis: %v\n", err)
+	}
+
+	slipEvents, err := s.GetSlipEventsPerCorner()
+	if err != nil {
+		log.Printf("No slip events per corner: %v\n", err)
+	}
+
+	shiftComparison, err := s.GetShiftComparison()
+	if err != nil {
+		log.Printf("No shift comparison: %v\n", err)
+	}
+
+	consistency, err := s.GetConsistencyReport()
+	if err != nil {
+		log.Printf("No consistency report: %v\n", err)
+	}
+
+	// the last lap is compared with the best lap
+	reference, err := s.GetBestLap()
+	if err != nil || reference.Number == lapToDraw.Number {
+		reference = Lap{}
+	}
+	traces, err := DrawTracesSVG(lapToDraw, reference)
+	if err != nil {
+		log.Printf("No traces: %v\n", err)
+	}
+
+	tireTemperatures := []TireTemperatures{}
+	for _, lap := range s.Laps {
+		tireTemperatures = append(tireTemperatures, lap.TireTemperatures)
+	}
+
 	return HeavyMessage{
-		FormattedLaps: formattedLaps,
-		LapSVG:        DrawLapToSVG(lapToDraw),
+		FormattedLaps:    formattedLaps,
+		LapSVG:           s.DrawTrackMapSVG(),
+		BrakingFeedback:  brakingFeedback,
+		Traction:         traction,
+		SlipEvents:       slipEvents,
+		ShiftFeedback:    shiftComparison.Feedback,
+		TireTemperatures: tireTemperatures,
+		Consistency:      consistency,
+		Traces:           traces,
 	}
 }
 
@@ -294,7 +465,7 @@ func (s *Stats) GetRealTimeMessage() RealTimeMessage {
 
 	durationSinceStart, err := s.GetDurationSinceStart()
 	if err != nil {
-		timeSinceStart = NoStartDetected
+		timeSinceStart = Translate(s.Settings.Language, NoStartDetected)
 		isValid = false
 	} else {
 		timeSinceStart = GetSportFormat(durationSinceStart)
@@ -333,7 +504,7 @@ func (s *Stats) GetRealTimeMessage() RealTimeMessage {
 		isValid = false
 	}
 
-	avgFuelConsumption, err := s.GetAverageFuelConsumptionPerLap()
+	avgFuelConsumption, avgFuelConsumptionSpread, err := getAverageFuelConsumption(s.getLapsForAverages(), s.Averaging)
 	if err != nil {
 		errorMessages = append(errorMessages, fmt.Sprintf("Avg Fuel Consumption unknown: %v", err))
 		isValid = false
@@ -365,75 +536,88 @@ func (s *Stats) GetRealTimeMessage() RealTimeMessage {
 		//isValid = false
 	}
 
-	position := s.GetCarPosition()
+	// Instant and ongoing lap consumption are not available at all times, e.g. while refuelling,
+	// so they do not mark the whole message invalid
+	fuelConsumptionInstant, err := s.GetInstantFuelConsumptionPerLap()
+	if err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("Instant fuel consumption unknown: %v", err))
+	}
 
-	message := RealTimeMessage{
-		Speed:                      fmt.Sprintf("%.0f", s.LastData.CarSpeed),
-		PackageID:                  s.LastData.PackageID,
-		FuelLeft:                   fmt.Sprintf("%.2f", s.LastData.CurrentFuel),
-		FuelConsumptionLastLap:     fmt.Sprintf("%.2f", fuelConsumptionLastLap),
-		FuelConsumptionAvg:         fmt.Sprintf("%.2f", avgFuelConsumption),
-		FuelConsumptionPerMinute:   fmt.Sprintf("%.2f", fuelConsumptionPerMinute),
-		TimeSinceStart:             timeSinceStart,
-		FuelNeededToFinishRace:     RoundUpAlways(fuelNeededToFinishRaceInTotal),
-		LapsLeftInRace:             lapsLeftInRace,
-		EndOfRaceType:              s.getEndOfRaceType(),
-		FuelDiv:                    fmt.Sprintf("%.0f", fuelDiv),
-		RaceTimeInMinutes:          int32(raceduration.Minutes()),
-		ValidState:                 isValid,
-		LowestTireTemp:             float32(minTemp),
-		ErrorMessage:               errorMessage,
-		NextPitStop:                int16(nextPitStop),
-		CurrentLapProgressAdjusted: fmt.Sprintf("%.1f", currentLapProgressAdjusted),
-		Tires:                      fmt.Sprintf("Front: %d%%, %d%% Rear: %d%%, %d%%", s.LastTireData.FrontLeft, s.LastTireData.FrontRight, s.LastTireData.RearLeft, s.LastTireData.RearRight),
-		LapTimeDeviation:           GetSportFormat(laptimedevitaion),
-		TireTemperatures:           []int{int(s.LastData.TyreTempFL), int(s.LastData.TyreTempFR), int(s.LastData.TyreTempRL), int(s.LastData.TyreTempRR)},
-		TCSActive:                  s.LastData.IsTCSEngaged,
-		ASMActive:                  s.LastData.IsASMEngaged,
-		RisingTrailbreaking:        s.History.IsTrailBreakingIncreasing(),
-		Position:                   position,
+	fuelConsumptionInstantPerMinute, err := s.GetInstantFuelConsumptionPerMinute()
+	if err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("Instant fuel consumption per minute unknown: %v", err))
 	}
-	return message
 
-}
+	fuelConsumptionCurrentLap, err := s.GetProjectedFuelConsumptionCurrentLap()
+	if err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("Fuel consumption current lap unknown: %v", err))
+	}
 
-func getHtmlTableForLaps(laps []Lap) string {
+	fuelEstimate, err := s.GetFuelConsumptionEstimate()
+	if err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("Fuel consumption estimate unknown: %v", err))
+		// The estimate is meant for the time before the other values are available,
+		// so it should not be able to mark the whole message invalid
+	}
 
-	// Header
-	html := fmt.Sprintf("<table class='laptable'>" +
-		"\t<tr>\n" +
-		"\t\t<th>#</th>\n" +
-		"\t\t<th>Duration</th>\n" +
-		"\t\t<th>Time</th>\n" +
-		"\t\t<th>Top Speed</th>\n" +
-		"\t\t<th>Fuel Consumed</th>\n" +
-		"\t\t<th>Tires Consumed</th>\n" +
-		"\t</tr>\n",
-	)
+	strategy, err := s.GetStrategy()
+	if err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("Strategy unknown: %v", err))
+	}
 
-	for i := len(laps) - 1; i >= 0; i-- {
+	multiplierWarning := ""
+	err = s.CheckFuelMultiplier()
+	if err != nil {
+		multiplierWarning = err.Error()
+	}
 
-		lap := laps[i]
+	position := s.GetCarPosition()
 
-		html += fmt.Sprintf(
-			"\t<tr>\n"+
-				"\t\t<td>%d</td>\n"+
-				"\t\t<td>%s</td>\n"+
-				"\t\t<td>%s</td>\n"+
-				"\t\t<td>%.0f</td>\n"+
-				"\t\t<td>%.1f%%</td>\n"+
-				"\t\t<td>%s</td>\n"+
-				"\t</tr>\n",
-			lap.Number,
-			GetSportFormat(lap.GetTotalRaceDurationAtEndOfLap()),
-			GetSportFormat(lap.Duration),
-			lap.GetTopSpeed(),
-			lap.GetFuelConsumed(),
-			lap.TiresStart.Diff(lap.TiresEnd).Format(),
-		)
+	message := RealTimeMessage{
+		Speed:                           fmt.Sprintf("%.0f", s.LastData.CarSpeed),
+		PackageID:                       s.LastData.PackageID,
+		FuelLeft:                        fmt.Sprintf("%.2f", s.LastData.CurrentFuel),
+		FuelConsumptionLastLap:          fmt.Sprintf("%.2f", fuelConsumptionLastLap),
+		FuelConsumptionAvg:              fmt.Sprintf("%.2f", avgFuelConsumption),
+		FuelConsumptionAvgMethod:        s.Averaging.Method,
+		FuelConsumptionAvgSpread:        fmt.Sprintf("%.2f", avgFuelConsumptionSpread),
+		FuelConsumptionPerMinute:        fmt.Sprintf("%.2f", fuelConsumptionPerMinute),
+		TimeSinceStart:                  timeSinceStart,
+		FuelNeededToFinishRace:          RoundUpAlways(fuelNeededToFinishRaceInTotal),
+		LapsLeftInRace:                  lapsLeftInRace,
+		EndOfRaceType:                   s.getEndOfRaceType(),
+		FuelDiv:                         fmt.Sprintf("%.0f", fuelDiv),
+		RaceTimeInMinutes:               int32(raceduration.Minutes()),
+		ValidState:                      isValid,
+		LowestTireTemp:                  float32(minTemp),
+		ErrorMessage:                    errorMessage,
+		NextPitStop:                     int16(nextPitStop),
+		CurrentLapProgressAdjusted:      fmt.Sprintf("%.1f", currentLapProgressAdjusted),
+		Tires:                           fmt.Sprintf("Front: %d%%, %d%% Rear: %d%%, %d%%", s.LastTireData.FrontLeft, s.LastTireData.FrontRight, s.LastTireData.RearLeft, s.LastTireData.RearRight),
+		LapTimeDeviation:                GetSportFormat(laptimedevitaion),
+		TireTemperatures:                []int{int(s.LastData.TyreTempFL), int(s.LastData.TyreTempFR), int(s.LastData.TyreTempRL), int(s.LastData.TyreTempRR)},
+		TCSActive:                       s.LastData.IsTCSEngaged,
+		SlipAlert:                       s.GetSlipAlert(),
+		ShiftLight:                      GetShiftLight(*s.LastData),
+		HealthWarning:                   s.GetHealthWarning(),
+		ColdTireWarning:                 s.GetColdTireWarning(),
+		ASMActive:                       s.LastData.IsASMEngaged,
+		RisingTrailbreaking:             s.History.IsTrailBreakingIncreasing(),
+		Position:                        position,
+		FuelConsumptionInstant:          fmt.Sprintf("%.2f", fuelConsumptionInstant),
+		FuelConsumptionInstantPerMinute: fmt.Sprintf("%.2f", fuelConsumptionInstantPerMinute),
+		FuelConsumptionCurrentLap:       fmt.Sprintf("%.2f", fuelConsumptionCurrentLap),
+		FuelConsumptionEstimate:         fmt.Sprintf("%.2f", fuelEstimate.ConsumptionPerLap),
+		FuelEstimateConfidence:          fuelEstimate.Confidence,
+		FuelEstimateSource:              fuelEstimate.Source,
+		PitStopsNeeded:                  int16(strategy.PitStops),
+		StrategyLimitedBy:               strategy.LimitedBy,
+		PitLoss:                         GetSportFormat(strategy.PitLoss),
+		TotalPitLoss:                    GetSportFormat(strategy.GetTotalPitLoss()),
+		MultiplierWarning:               multiplierWarning,
 	}
-	html += "</table>\n"
-	return html
+	return message
+
 }
 
 func (s *Stats) getValidState() bool {
@@ -732,7 +916,7 @@ func (s *Stats) GetCarPosition() CarPosition {
 	return CarPosition{
 		X:      s.LastData.PositionX,
 		Y:      s.LastData.PositionZ,
-		Facing: s.LastData.PositionY,
+		Facing: GetHeading(*s.LastData),
 	}
 
 }
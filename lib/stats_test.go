@@ -3,6 +3,7 @@ package lib
 import (
 	"fmt"
 	"github.com/jmhodges/clock"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
 	"github.com/snipem/gt7fuel/lib/experimental"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -168,25 +169,34 @@ func TestStats_GetMessage(t *testing.T) {
 		s := NewStats()
 		s.setClock(clock.NewFake())
 		assert.Equal(t, RealTimeMessage{
-			Speed:                      "0",
-			PackageID:                  0,
-			FuelLeft:                   "0.00",
-			FuelConsumptionLastLap:     "-1.00",
-			TimeSinceStart:             NoStartDetected,
-			FuelNeededToFinishRace:     -1,
-			FuelConsumptionAvg:         "-1.00",
-			FuelDiv:                    "-1",
-			RaceTimeInMinutes:          0,
-			ValidState:                 false,
-			LapsLeftInRace:             -1,
-			EndOfRaceType:              "By Time",
-			FuelConsumptionPerMinute:   "-1.00",
-			ErrorMessage:               "Laps left in race unknown: error getting duration since start: race start time is not detected, cannot get time since start\nFuel needed to finish race unknown: error getting fuel consumption last lap: not enough laps to return fuel consumption of last lap, nr of laps: 0\nFuel Div unknown: error getting fuel needed to finish race: error getting fuel consumption last lap: not enough laps to return fuel consumption of last lap, nr of laps: 0",
-			NextPitStop:                -1,
-			CurrentLapProgressAdjusted: "-1.0",
-			LapTimeDeviation:           "00:00.000",
-			TireTemperatures:           []int{0, 0, 0, 0},
-			Tires:                      "Front: 0%, 0% Rear: 0%, 0%",
+			Speed:                           "0",
+			PackageID:                       0,
+			FuelLeft:                        "0.00",
+			FuelConsumptionLastLap:          "-1.00",
+			TimeSinceStart:                  NoStartDetected,
+			FuelNeededToFinishRace:          -1,
+			FuelConsumptionAvg:              "-1.00",
+			FuelConsumptionAvgMethod:        AverageMean,
+			FuelConsumptionAvgSpread:        "-1.00",
+			FuelDiv:                         "-1",
+			RaceTimeInMinutes:               0,
+			ValidState:                      false,
+			LapsLeftInRace:                  -1,
+			EndOfRaceType:                   "By Time",
+			FuelConsumptionPerMinute:        "-1.00",
+			ErrorMessage:                    "Laps left in race unknown: error getting duration since start: race start time is not detected, cannot get time since start\nFuel needed to finish race unknown: error getting fuel consumption last lap: not enough laps to return fuel consumption of last lap, nr of laps: 0\nFuel Div unknown: error getting fuel needed to finish race: error getting fuel consumption last lap: not enough laps to return fuel consumption of last lap, nr of laps: 0",
+			NextPitStop:                     -1,
+			CurrentLapProgressAdjusted:      "-1.0",
+			LapTimeDeviation:                "00:00.000",
+			TireTemperatures:                []int{0, 0, 0, 0},
+			FuelConsumptionInstant:          "-1.00",
+			FuelConsumptionInstantPerMinute: "-1.00",
+			FuelConsumptionCurrentLap:       "-1.00",
+			FuelConsumptionEstimate:         "-1.00",
+			PitStopsNeeded:                  -1,
+			PitLoss:                         "00:30.000",
+			TotalPitLoss:                    "00:00.000",
+			Tires:                           "Front: 0%, 0% Rear: 0%, 0%",
 		}, s.GetRealTimeMessage())
 	})
 
@@ -208,25 +218,36 @@ func TestStats_GetMessage(t *testing.T) {
 		s.OngoingLap = getReasonableOngoingLap()
 
 		assert.Equal(t, RealTimeMessage{
-			Speed:                      "100",
-			PackageID:                  4711,
-			FuelLeft:                   "20.00",
-			FuelConsumptionLastLap:     "25.00",
-			TimeSinceStart:             "10:00.500",
-			FuelNeededToFinishRace:     192,
-			FuelConsumptionAvg:         "25.00",
-			FuelDiv:                    "172",
-			RaceTimeInMinutes:          33,
-			ValidState:                 true,
-			LapsLeftInRace:             7,
-			EndOfRaceType:              "By Time",
-			FuelConsumptionPerMinute:   "16.67",
-			NextPitStop:                5,
-			CurrentLapProgressAdjusted: "5.3",
-			ErrorMessage:               "",
-			Tires:                      "Front: 0%, 0% Rear: 0%, 0%",
-			LapTimeDeviation:           "00:00.000",
-			TireTemperatures:           []int{0, 0, 0, 0},
+			Speed:                           "100",
+			PackageID:                       4711,
+			FuelLeft:                        "20.00",
+			FuelConsumptionLastLap:          "25.00",
+			TimeSinceStart:                  "10:00.500",
+			FuelNeededToFinishRace:          192,
+			FuelConsumptionAvg:              "25.00",
+			FuelConsumptionAvgMethod:        AverageMean,
+			FuelConsumptionAvgSpread:        "0.00",
+			FuelDiv:                         "172",
+			RaceTimeInMinutes:               33,
+			ValidState:                      true,
+			LapsLeftInRace:                  7,
+			EndOfRaceType:                   "By Time",
+			FuelConsumptionPerMinute:        "16.67",
+			NextPitStop:                     5,
+			CurrentLapProgressAdjusted:      "5.3",
+			ErrorMessage:                    "",
+			Tires:                           "Front: 0%, 0% Rear: 0%, 0%",
+			LapTimeDeviation:                "00:00.000",
+			TireTemperatures:                []int{0, 0, 0, 0},
+			FuelConsumptionInstant:          "-1.00",
+			FuelConsumptionInstantPerMinute: "-1.00",
+			FuelConsumptionCurrentLap:       "-1.00",
+			FuelConsumptionEstimate:         "25.00",
+			PitStopsNeeded:                  -1,
+			PitLoss:                         "00:30.000",
+			TotalPitLoss:                    "00:00.000",
+			FuelEstimateConfidence:          0.33333334,
+			FuelEstimateSource:              "live",
 		}, s.GetRealTimeMessage())
 	})
 
@@ -248,24 +269,35 @@ func TestStats_GetMessage(t *testing.T) {
 		s.OngoingLap = getReasonableOngoingLap()
 
 		assert.Equal(t, RealTimeMessage{
-			Speed:                      "100",
-			PackageID:                  4711,
-			FuelLeft:                   "20.00",
-			FuelConsumptionLastLap:     "25.00",
-			TimeSinceStart:             "10:00.500",
-			FuelNeededToFinishRace:     167,
-			FuelConsumptionAvg:         "25.00",
-			FuelDiv:                    "147",
-			RaceTimeInMinutes:          30, // total laps * best lap
-			ValidState:                 true,
-			LapsLeftInRace:             6,
-			EndOfRaceType:              ByLaps,
-			FuelConsumptionPerMinute:   "16.67",
-			NextPitStop:                5,
-			CurrentLapProgressAdjusted: "5.3",
-			Tires:                      "Front: 0%, 0% Rear: 0%, 0%",
-			LapTimeDeviation:           "00:00.000",
-			TireTemperatures:           []int{0, 0, 0, 0},
+			Speed:                           "100",
+			PackageID:                       4711,
+			FuelLeft:                        "20.00",
+			FuelConsumptionLastLap:          "25.00",
+			TimeSinceStart:                  "10:00.500",
+			FuelNeededToFinishRace:          167,
+			FuelConsumptionAvg:              "25.00",
+			FuelConsumptionAvgMethod:        AverageMean,
+			FuelConsumptionAvgSpread:        "0.00",
+			FuelDiv:                         "147",
+			RaceTimeInMinutes:               30, // total laps * best lap
+			ValidState:                      true,
+			LapsLeftInRace:                  6,
+			EndOfRaceType:                   ByLaps,
+			FuelConsumptionPerMinute:        "16.67",
+			NextPitStop:                     5,
+			CurrentLapProgressAdjusted:      "5.3",
+			Tires:                           "Front: 0%, 0% Rear: 0%, 0%",
+			LapTimeDeviation:                "00:00.000",
+			TireTemperatures:                []int{0, 0, 0, 0},
+			FuelConsumptionInstant:          "-1.00",
+			FuelConsumptionInstantPerMinute: "-1.00",
+			FuelConsumptionCurrentLap:       "-1.00",
+			FuelConsumptionEstimate:         "25.00",
+			PitStopsNeeded:                  -1,
+			PitLoss:                         "00:30.000",
+			TotalPitLoss:                    "00:00.000",
+			FuelEstimateConfidence:          0.33333334,
+			FuelEstimateSource:              "live",
 		}, s.GetRealTimeMessage())
 	})
 
@@ -291,25 +323,36 @@ func TestStats_GetMessage(t *testing.T) {
 		s.OngoingLap = getReasonableOngoingLap()
 
 		assert.Equal(t, RealTimeMessage{
-			Speed:                      "100",
-			PackageID:                  4711,
-			FuelLeft:                   "100.00",
-			FuelConsumptionLastLap:     "0.00",
-			TimeSinceStart:             "10:00.500",
-			FuelNeededToFinishRace:     0,
-			FuelConsumptionAvg:         "0.00",
-			FuelDiv:                    "-100",
-			RaceTimeInMinutes:          33,
-			ValidState:                 true,
-			LapsLeftInRace:             7,
-			EndOfRaceType:              "By Time",
-			FuelConsumptionPerMinute:   "0.00",
-			ErrorMessage:               "",
-			NextPitStop:                -1,
-			CurrentLapProgressAdjusted: "0.3",
-			Tires:                      "Front: 0%, 0% Rear: 0%, 0%",
-			LapTimeDeviation:           "00:00.000",
-			TireTemperatures:           []int{0, 0, 0, 0},
+			Speed:                           "100",
+			PackageID:                       4711,
+			FuelLeft:                        "100.00",
+			FuelConsumptionLastLap:          "0.00",
+			TimeSinceStart:                  "10:00.500",
+			FuelNeededToFinishRace:          0,
+			FuelConsumptionAvg:              "0.00",
+			FuelConsumptionAvgMethod:        AverageMean,
+			FuelConsumptionAvgSpread:        "0.00",
+			FuelDiv:                         "-100",
+			RaceTimeInMinutes:               33,
+			ValidState:                      true,
+			LapsLeftInRace:                  7,
+			EndOfRaceType:                   "By Time",
+			FuelConsumptionPerMinute:        "0.00",
+			ErrorMessage:                    "",
+			NextPitStop:                     -1,
+			CurrentLapProgressAdjusted:      "0.3",
+			Tires:                           "Front: 0%, 0% Rear: 0%, 0%",
+			LapTimeDeviation:                "00:00.000",
+			TireTemperatures:                []int{0, 0, 0, 0},
+			FuelConsumptionInstant:          "-1.00",
+			FuelConsumptionInstantPerMinute: "-1.00",
+			FuelConsumptionCurrentLap:       "-1.00",
+			FuelConsumptionEstimate:         "0.00",
+			PitStopsNeeded:                  -1,
+			PitLoss:                         "00:30.000",
+			TotalPitLoss:                    "00:00.000",
+			FuelEstimateConfidence:          1,
+			FuelEstimateSource:              "live",
 		}, s.GetRealTimeMessage())
 	})
 
@@ -494,7 +537,8 @@ func TestStats_GetFuelNeededToFinishRaceInTotal(t *testing.T) {
 }
 
 func Test_formatLaps(t *testing.T) {
-	formattedLaps := getHtmlTableForLaps(getReasonableLaps())
+	formattedLaps, err := getHtmlTableForLaps(getReasonableLaps(), []SpeedTrap{{Name: "Main straight", Distance: 1000}}, nil, LanguageEnglish)
+	assert.NoError(t, err)
 	fmt.Println(formattedLaps)
 }
 
@@ -613,3 +657,23 @@ func Test_packagesToDuration(t *testing.T) {
 	assert.Equal(t, 1*time.Hour, packageNumbersToDuration(int32(oneHourInPackages)))
 
 }
+
+func TestGetLap(t *testing.T) {
+	laps := []Lap{{Number: 1}, {Number: 2}}
+	lap, err := GetLap(laps, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int16(2), lap.Number)
+
+	_, err = GetLap(laps, 3)
+	assert.Error(t, err)
+}
+
+// getLiveSamples keeps every nth package of the lap like LogRace does live, where only about every sixth package is stored
+func getLiveSamples(lap Lap, every int) Lap {
+	sampled := lap
+	sampled.DataHistory = []gt7.GTData{}
+	for i := 0; i < len(lap.DataHistory); i += every {
+		sampled.DataHistory = append(sampled.DataHistory, lap.DataHistory[i])
+	}
+	return sampled
+}
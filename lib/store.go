@@ -0,0 +1,160 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const storedLapsFilename = "laps.jsonl"
+
+// Store persists lap summaries across races, so that a new race with a known
+// car and track does not have to start without any fuel figures
+type Store struct {
+	Dir string
+	// laps caches the stored laps, they are read once and then kept in sync with the file
+	laps  []StoredLap
+	mutex sync.Mutex
+}
+
+type StoredLap struct {
+	CarID        int32         `json:"car_id"`
+	Track        string        `json:"track"`
+	Number       int16         `json:"number"`
+	Duration     time.Duration `json:"duration"`
+	FuelConsumed float32       `json:"fuel_consumed"`
+	Distance     float32       `json:"distance"`
+	// TireWear is the wear of the most worn tire in percent, -1 if unknown
+	TireWear       int       `json:"tire_wear"`
+	FuelMultiplier float32   `json:"fuel_multiplier"`
+	TireMultiplier float32   `json:"tire_multiplier"`
+	Recorded       time.Time `json:"recorded"`
+	Health         LapHealth `json:"health"`
+	// Session is the run of gt7fuel the lap was driven in
+	Session string `json:"session,omitempty"`
+}
+
+func NewStore(dir string) (*Store, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("error creating store dir %s: %v", dir, err)
+	}
+	return &Store{Dir: dir}, nil
+}
+
+func newStoredLap(carID int32, track string, session string, lap Lap, settings RaceSettings, recorded time.Time) StoredLap {
+	tireWear, err := lap.GetTireWear()
+	if err != nil {
+		tireWear = -1
+	}
+	return StoredLap{
+		CarID:          carID,
+		Track:          track,
+		Number:         lap.Number,
+		Duration:       lap.Duration,
+		FuelConsumed:   lap.GetFuelConsumed(),
+		Distance:       lap.GetDistance(),
+		TireWear:       tireWear,
+		FuelMultiplier: settings.FuelMultiplier,
+		TireMultiplier: settings.TireMultiplier,
+		Recorded:       recorded,
+		Health:         GetLapHealth(lap),
+		Session:        session,
+	}
+}
+
+// AddLap appends a lap to the store
+func (st *Store) AddLap(lap StoredLap) error {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	err := st.readLaps()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(st.Dir, storedLapsFilename), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening stored laps: %v", err)
+	}
+	defer f.Close()
+
+	err = json.NewEncoder(f).Encode(lap)
+	if err != nil {
+		return fmt.Errorf("error writing stored lap: %v", err)
+	}
+	st.laps = append(st.laps, lap)
+	return nil
+}
+
+// GetLaps returns all stored laps driven with the car on the track
+func (st *Store) GetLaps(carID int32, track string) ([]StoredLap, error) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	err := st.readLaps()
+	if err != nil {
+		return nil, err
+	}
+
+	laps := []StoredLap{}
+	for _, lap := range st.laps {
+		if lap.CarID == carID && lap.Track == track {
+			laps = append(laps, lap)
+		}
+	}
+	return laps, nil
+}
+
+// readLaps reads the stored laps from disk if they have not been read yet
+func (st *Store) readLaps() error {
+	if st.laps != nil {
+		return nil
+	}
+
+	f, err := os.Open(filepath.Join(st.Dir, storedLapsFilename))
+	if os.IsNotExist(err) {
+		st.laps = []StoredLap{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening stored laps: %v", err)
+	}
+	defer f.Close()
+
+	laps := []StoredLap{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lap := StoredLap{}
+		err := json.Unmarshal(scanner.Bytes(), &lap)
+		if err != nil {
+			return fmt.Errorf("error reading stored lap: %v", err)
+		}
+		laps = append(laps, lap)
+	}
+	if scanner.Err() != nil {
+		return fmt.Errorf("error reading stored laps: %v", scanner.Err())
+	}
+	st.laps = laps
+	return nil
+}
+
+// getStoredLapsOfPreviousSessions returns the stored laps of the car on the track, the laps of the
+// current session are left out as they are already counted as driven laps
+func (s *Stats) getStoredLapsOfPreviousSessions() ([]StoredLap, error) {
+	storedLaps, err := s.Store.GetLaps(s.LastData.CarID, s.Track)
+	if err != nil {
+		return nil, fmt.Errorf("error getting stored laps: %v", err)
+	}
+	laps := []StoredLap{}
+	for _, lap := range storedLaps {
+		if lap.Session != s.sessionID {
+			laps = append(laps, lap)
+		}
+	}
+	return laps, nil
+}
@@ -0,0 +1,34 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestStore_AddLap(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	assert.NoError(t, err)
+
+	laps, err := store.GetLaps(1234, "Suzuka")
+	assert.NoError(t, err)
+	assert.Len(t, laps, 0)
+
+	assert.NoError(t, store.AddLap(StoredLap{CarID: 1234, Track: "Suzuka", Number: 2, FuelConsumed: 3.5, Duration: 2 * time.Minute}))
+	assert.NoError(t, store.AddLap(StoredLap{CarID: 1234, Track: "Suzuka", Number: 3, FuelConsumed: 3.4, Duration: 2 * time.Minute}))
+	assert.NoError(t, store.AddLap(StoredLap{CarID: 1234, Track: "Monza", Number: 2, FuelConsumed: 2.5}))
+	assert.NoError(t, store.AddLap(StoredLap{CarID: 4321, Track: "Suzuka", Number: 2, FuelConsumed: 4.5}))
+
+	laps, err = store.GetLaps(1234, "Suzuka")
+	assert.NoError(t, err)
+	assert.Len(t, laps, 2)
+	assert.Equal(t, float32(3.4), laps[1].FuelConsumed)
+	assert.Equal(t, 2*time.Minute, laps[1].Duration)
+
+	// A new store reads the laps from disk
+	reopenedStore, err := NewStore(store.Dir)
+	assert.NoError(t, err)
+	laps, err = reopenedStore.GetLaps(4321, "Suzuka")
+	assert.NoError(t, err)
+	assert.Len(t, laps, 1)
+}
@@ -0,0 +1,151 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const LimitedByFuel = "fuel"
+const LimitedByTires = "tires"
+
+// Strategy is the plan for the rest of the race
+type Strategy struct {
+	PitStops int
+	// FuelLapsPerStint is the number of laps a full tank lasts, -1 if no fuel is consumed
+	FuelLapsPerStint float32
+	// TireLapsPerStint is the number of laps a fresh set of tires lasts, -1 if tire wear is unknown
+	TireLapsPerStint float32
+	LimitedBy        string
+	// PitLoss is the time lost per pit stop
+	PitLoss time.Duration
+}
+
+// getTireWearPriorFromStoredLaps averages the tire wear of regular laps of previous races,
+// the wear is scaled from the multiplier of the stored lap to the given one
+func getTireWearPriorFromStoredLaps(storedLaps []StoredLap, tireMultiplier float32) (float32, error) {
+	var tireWear float32
+	n := 0
+	for _, lap := range storedLaps {
+		// laps stored without tire multiplier have not recorded their wear
+		if lap.Number < 2 || lap.FuelConsumed <= 0 || lap.TireWear < 0 || lap.TireMultiplier <= 0 {
+			continue
+		}
+		tireWear += float32(lap.TireWear) / lap.TireMultiplier * getMultiplier(tireMultiplier)
+		n++
+	}
+	if n == 0 {
+		return -1, fmt.Errorf("no stored laps with tire wear")
+	}
+	return tireWear / float32(n), nil
+}
+
+// GetTireWearPerLap returns the tire wear of the most worn tire per lap in percent.
+// It is measured from the driven laps or taken from previous races
+func (s *Stats) GetTireWearPerLap() (float32, error) {
+	var tireWear float32
+	n := 0
+	for _, lap := range s.Laps {
+		wear, err := lap.GetTireWear()
+		if err != nil || !lap.IsRegularLap() {
+			continue
+		}
+		tireWear += float32(wear)
+		n++
+	}
+	if n > 0 {
+		return tireWear / float32(n), nil
+	}
+
+	if s.Store == nil {
+		return -1, fmt.Errorf("no laps with tire data and no store available")
+	}
+	storedLaps, err := s.getStoredLapsOfPreviousSessions()
+	if err != nil {
+		return -1, err
+	}
+	return getTireWearPriorFromStoredLaps(storedLaps, s.Settings.TireMultiplier)
+}
+
+// GetStrategy plans the pit stops for the rest of the race based on the estimated fuel consumption and tire wear
+func (s *Stats) GetStrategy() (Strategy, error) {
+	pitLoss := s.GetPitLoss()
+
+	fuelEstimate, err := s.GetFuelConsumptionEstimate()
+	if err != nil {
+		return Strategy{PitStops: -1, PitLoss: pitLoss}, fmt.Errorf("error getting fuel consumption estimate: %v", err)
+	}
+
+	lapsLeft, err := s.GetProgressAdjustedLapsLeftInRace()
+	if err != nil {
+		return Strategy{PitStops: -1, PitLoss: pitLoss}, fmt.Errorf("error getting laps left in race: %v", err)
+	}
+
+	tireWearPerLap, err := s.GetTireWearPerLap()
+	if err != nil {
+		tireWearPerLap = -1
+	}
+
+	strategy, err := planStrategy(lapsLeft, s.LastData.CurrentFuel, s.LastData.FuelCapacity, fuelEstimate.ConsumptionPerLap,
+		float32(s.getLowestTireLeft()), tireWearPerLap)
+	strategy.PitLoss = pitLoss
+	return strategy, err
+}
+
+// getLowestTireLeft returns the remaining percentage of the most worn tire, 100 if unknown
+func (s *Stats) getLowestTireLeft() int {
+	if s.LastTireData.LastWrite.IsZero() {
+		return 100
+	}
+	lowest := s.LastTireData.FrontLeft
+	for _, t := range []int{s.LastTireData.FrontRight, s.LastTireData.RearLeft, s.LastTireData.RearRight} {
+		if t < lowest {
+			lowest = t
+		}
+	}
+	return lowest
+}
+
+// planStrategy calculates the pit stops needed for the laps left, a negative tire wear means it is unknown
+func planStrategy(lapsLeft float32, fuelLeft float32, fuelCapacity float32, fuelPerLap float32, tireLeft float32, tireWearPerLap float32) (Strategy, error) {
+	if fuelCapacity <= 0 {
+		return Strategy{PitStops: -1}, fmt.Errorf("fuel capacity is %.0f, impossible to plan strategy", fuelCapacity)
+	}
+
+	strategy := Strategy{TireLapsPerStint: -1, LimitedBy: LimitedByFuel}
+
+	fuelStops := 0
+	if fuelPerLap > 0 {
+		strategy.FuelLapsPerStint = fuelCapacity / fuelPerLap
+		fuelMissing := lapsLeft*fuelPerLap - fuelLeft
+		if fuelMissing > 0 {
+			fuelStops = int(math.Ceil(float64(fuelMissing / fuelCapacity)))
+		}
+	} else {
+		strategy.FuelLapsPerStint = -1
+	}
+
+	tireStops := 0
+	if tireWearPerLap > 0 {
+		strategy.TireLapsPerStint = 100 / tireWearPerLap
+		lapsMissing := lapsLeft - tireLeft/tireWearPerLap
+		if lapsMissing > 0 {
+			tireStops = int(math.Ceil(float64(lapsMissing / strategy.TireLapsPerStint)))
+		}
+	}
+
+	strategy.PitStops = fuelStops
+	if tireStops > fuelStops {
+		strategy.PitStops = tireStops
+		strategy.LimitedBy = LimitedByTires
+	}
+	return strategy, nil
+}
+
+// GetTotalPitLoss returns the time that will be lost in the pits for the rest of the race
+func (st Strategy) GetTotalPitLoss() time.Duration {
+	if st.PitStops <= 0 {
+		return 0
+	}
+	return time.Duration(st.PitStops) * st.PitLoss
+}
@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"github.com/snipem/gt7fuel/lib/experimental"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_planStrategy(t *testing.T) {
+	t.Run("No stop needed", func(t *testing.T) {
+		strategy, err := planStrategy(10, 100, 100, 5, 100, -1)
+		assert.NoError(t, err)
+		assert.Equal(t, Strategy{PitStops: 0, FuelLapsPerStint: 20, TireLapsPerStint: -1, LimitedBy: LimitedByFuel}, strategy)
+	})
+
+	t.Run("Fuel stops", func(t *testing.T) {
+		// 250 fuel needed, 100 in the tank
+		strategy, err := planStrategy(50, 100, 100, 5, 100, -1)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, strategy.PitStops)
+		assert.Equal(t, LimitedByFuel, strategy.LimitedBy)
+	})
+
+	t.Run("Tire stops", func(t *testing.T) {
+		// tires last 10 laps, 5 laps left on the current set
+		strategy, err := planStrategy(30, 100, 100, 2, 50, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, strategy.PitStops)
+		assert.Equal(t, float32(10), strategy.TireLapsPerStint)
+		assert.Equal(t, LimitedByTires, strategy.LimitedBy)
+	})
+
+	t.Run("No fuel capacity", func(t *testing.T) {
+		_, err := planStrategy(30, 100, 0, 2, 50, 10)
+		assert.Error(t, err)
+	})
+}
+
+func Test_getTireWearPriorFromStoredLaps(t *testing.T) {
+	wear, err := getTireWearPriorFromStoredLaps([]StoredLap{
+		{Number: 2, FuelConsumed: 1, TireWear: 2, TireMultiplier: 1},
+		{Number: 3, FuelConsumed: 1, TireWear: 6, TireMultiplier: 3},
+		{Number: 4, FuelConsumed: 1, TireWear: 9}, // stored without tire wear
+	}, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(10), wear)
+
+	_, err = getTireWearPriorFromStoredLaps([]StoredLap{}, 1)
+	assert.Error(t, err)
+}
+
+func TestStats_GetTireWearPerLap(t *testing.T) {
+	s := NewStats()
+	_, err := s.GetTireWearPerLap()
+	assert.Error(t, err)
+
+	now := time.Now()
+	tires := func(wear int) experimental.TireData {
+		return experimental.TireData{FrontLeft: 100 - wear, FrontRight: 100, RearLeft: 100, RearRight: 100, LastWrite: now}
+	}
+	s.Laps = []Lap{
+		{Number: 1, TiresStart: tires(0), TiresEnd: tires(5)}, // first lap is not regular
+		{Number: 2, TiresStart: tires(5), TiresEnd: tires(8)},
+		{Number: 3, TiresStart: tires(8), TiresEnd: tires(13)},
+	}
+	wear, err := s.GetTireWearPerLap()
+	assert.NoError(t, err)
+	assert.Equal(t, float32(4), wear)
+}
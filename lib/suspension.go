@@ -0,0 +1,248 @@
+package lib
+
+import (
+	"fmt"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"math"
+	"sort"
+	"time"
+)
+
+// suspensionBinWidth is the width of a suspension travel histogram bin in meters
+const suspensionBinWidth = 0.005
+
+// maxHistogramBins limits the number of bins of a histogram, values beyond the last bin are counted in it.
+// A single garbage reading must not allocate bins without bound
+const maxHistogramBins = 200
+
+// rideHeightSpeedBinWidth is the width of a speed bin for the ride height versus speed in km/h
+const rideHeightSpeedBinWidth = 20
+
+// bottomingRideHeightShare is the share of the median ride height below which the car bottoms out
+const bottomingRideHeightShare = 0.5
+
+type Histogram struct {
+	// Min is the lower bound of the first bin
+	Min      float32 `json:"min"`
+	BinWidth float32 `json:"bin_width"`
+	Counts   []int   `json:"counts"`
+}
+
+type SuspensionHistograms struct {
+	FrontLeft  Histogram `json:"front_left"`
+	FrontRight Histogram `json:"front_right"`
+	RearLeft   Histogram `json:"rear_left"`
+	RearRight  Histogram `json:"rear_right"`
+}
+
+type BottomingEvent struct {
+	// Start is the distance from the start of the lap in meters
+	Start float32 `json:"start"`
+	// RideHeight is the lowest ride height of the event in mm
+	RideHeight float32       `json:"ride_height"`
+	Duration   time.Duration `json:"duration"`
+	PositionX  float32       `json:"position_x"`
+	PositionZ  float32       `json:"position_z"`
+}
+
+type RideHeightAtSpeed struct {
+	// Speed is the lower bound of the speed bin
+	Speed      float32 `json:"speed"`
+	RideHeight float32 `json:"ride_height"`
+	Samples    int     `json:"samples"`
+}
+
+// CornerLoadTransfer is the difference in suspension travel in mm, Pitch is measured under braking and
+// positive if the front is more compressed, Roll is measured until the next braking zone and positive
+// if the left is more compressed
+type CornerLoadTransfer struct {
+	Corner int     `json:"corner"`
+	Pitch  float32 `json:"pitch"`
+	Roll   float32 `json:"roll"`
+}
+
+type LapSuspension struct {
+	Lap               int16                `json:"lap"`
+	Histograms        SuspensionHistograms `json:"histograms"`
+	Bottoming         []BottomingEvent     `json:"bottoming"`
+	RideHeightBySpeed []RideHeightAtSpeed  `json:"ride_height_by_speed"`
+	LoadTransfer      []CornerLoadTransfer `json:"load_transfer"`
+}
+
+// getHistogram counts the values in bins of binWidth starting at the smallest value, values that are
+// not finite are left out
+func getHistogram(values []float32, binWidth float32) Histogram {
+	finite := []float32{}
+	for _, v := range values {
+		if !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0) {
+			finite = append(finite, v)
+		}
+	}
+	if len(finite) == 0 {
+		return Histogram{BinWidth: binWidth, Counts: []int{}}
+	}
+	min, max := finite[0], finite[0]
+	for _, v := range finite {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	bins := maxHistogramBins
+	if float64((max-min)/binWidth) < maxHistogramBins {
+		bins = int((max-min)/binWidth) + 1
+	}
+	h := Histogram{Min: min, BinWidth: binWidth, Counts: make([]int, bins)}
+	for _, v := range finite {
+		bin := float64((v - min) / binWidth)
+		if bin >= float64(bins) {
+			bin = float64(bins - 1)
+		}
+		h.Counts[int(bin)]++
+	}
+	return h
+}
+
+func GetSuspensionHistograms(lap Lap) SuspensionHistograms {
+	fl, fr, rl, rr := []float32{}, []float32{}, []float32{}, []float32{}
+	for _, data := range lap.DataHistory {
+		fl = append(fl, data.SuspensionFL)
+		fr = append(fr, data.SuspensionFR)
+		rl = append(rl, data.SuspensionRL)
+		rr = append(rr, data.SuspensionRR)
+	}
+	return SuspensionHistograms{
+		FrontLeft:  getHistogram(fl, suspensionBinWidth),
+		FrontRight: getHistogram(fr, suspensionBinWidth),
+		RearLeft:   getHistogram(rl, suspensionBinWidth),
+		RearRight:  getHistogram(rr, suspensionBinWidth),
+	}
+}
+
+func getMedianRideHeight(lap Lap) float32 {
+	rideHeights := []float32{}
+	for _, data := range lap.DataHistory {
+		rideHeights = append(rideHeights, data.RideHeight)
+	}
+	if len(rideHeights) == 0 {
+		return 0
+	}
+	sort.Slice(rideHeights, func(i, j int) bool { return rideHeights[i] < rideHeights[j] })
+	return rideHeights[len(rideHeights)/2]
+}
+
+// GetBottomingEvents finds where the ride height drops far below the usual ride height of the lap
+func GetBottomingEvents(lap Lap) []BottomingEvent {
+	events := []BottomingEvent{}
+	threshold := getMedianRideHeight(lap) * bottomingRideHeightShare
+	distances := lap.GetDistances()
+
+	var event *BottomingEvent
+	for i, data := range lap.DataHistory {
+		if data.RideHeight >= threshold {
+			if event != nil {
+				events = append(events, *event)
+				event = nil
+			}
+			continue
+		}
+		if event == nil {
+			event = &BottomingEvent{
+				Start:      distances[i],
+				RideHeight: data.RideHeight,
+				PositionX:  data.PositionX,
+				PositionZ:  data.PositionZ,
+			}
+		}
+		event.Duration += getSampleDuration(lap.DataHistory, i)
+		if data.RideHeight < event.RideHeight {
+			event.RideHeight = data.RideHeight
+		}
+	}
+	if event != nil {
+		events = append(events, *event)
+	}
+	return events
+}
+
+// GetRideHeightBySpeed averages the ride height per speed bin, the bins are sorted by speed
+func GetRideHeightBySpeed(lap Lap) []RideHeightAtSpeed {
+	bins := map[int]*RideHeightAtSpeed{}
+	for _, data := range lap.DataHistory {
+		bin := int(data.CarSpeed / rideHeightSpeedBinWidth)
+		if bins[bin] == nil {
+			bins[bin] = &RideHeightAtSpeed{Speed: float32(bin * rideHeightSpeedBinWidth)}
+		}
+		bins[bin].RideHeight += data.RideHeight
+		bins[bin].Samples++
+	}
+
+	rideHeights := []RideHeightAtSpeed{}
+	for _, bin := range bins {
+		bin.RideHeight /= float32(bin.Samples)
+		rideHeights = append(rideHeights, *bin)
+	}
+	sort.Slice(rideHeights, func(i, j int) bool { return rideHeights[i].Speed < rideHeights[j].Speed })
+	return rideHeights
+}
+
+func getPitchAndRoll(data gt7.GTData) (float32, float32) {
+	pitch := (data.SuspensionFL+data.SuspensionFR)/2 - (data.SuspensionRL+data.SuspensionRR)/2
+	roll := (data.SuspensionFL+data.SuspensionRL)/2 - (data.SuspensionFR+data.SuspensionRR)/2
+	return pitch * 1000, roll * 1000
+}
+
+// GetLoadTransfer averages the pitch in every braking zone and the roll from the braking zone to the next one
+func GetLoadTransfer(lap Lap) []CornerLoadTransfer {
+	transfers := []CornerLoadTransfer{}
+	zones := GetBrakingZones(lap)
+	for z, zone := range zones {
+		end := len(lap.DataHistory)
+		if z+1 < len(zones) {
+			end = zones[z+1].startIndex
+		}
+
+		transfer := CornerLoadTransfer{Corner: zone.Corner}
+		for i := zone.startIndex; i <= zone.endIndex; i++ {
+			pitch, _ := getPitchAndRoll(lap.DataHistory[i])
+			transfer.Pitch += pitch
+		}
+		transfer.Pitch /= float32(zone.endIndex - zone.startIndex + 1)
+
+		for i := zone.startIndex; i < end; i++ {
+			_, roll := getPitchAndRoll(lap.DataHistory[i])
+			transfer.Roll += roll
+		}
+		transfer.Roll /= float32(end - zone.startIndex)
+
+		transfers = append(transfers, transfer)
+	}
+	return transfers
+}
+
+func GetLapSuspension(lap Lap) LapSuspension {
+	return LapSuspension{
+		Lap:               lap.Number,
+		Histograms:        GetSuspensionHistograms(lap),
+		Bottoming:         GetBottomingEvents(lap),
+		RideHeightBySpeed: GetRideHeightBySpeed(lap),
+		LoadTransfer:      GetLoadTransfer(lap),
+	}
+}
+
+// GetSuspensionReport analyses the suspension of every lap with data
+func (s *Stats) GetSuspensionReport() ([]LapSuspension, error) {
+	report := []LapSuspension{}
+	for _, lap := range s.Laps {
+		if len(lap.DataHistory) == 0 {
+			continue
+		}
+		report = append(report, GetLapSuspension(lap))
+	}
+	if len(report) == 0 {
+		return report, fmt.Errorf("no laps with data")
+	}
+	return report, nil
+}
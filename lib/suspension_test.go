@@ -0,0 +1,106 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+// getLapWithSuspension brakes at the given packages like getLapWithBrakingZones. The front is
+// compressed by 20mm while braking, the left by 10mm after the brake point and the ride height drops
+// to 10mm for 5 packages at bottomAt
+func getLapWithSuspension(brakeAt []int32, bottomAt int) Lap {
+	lap := getLapWithBrakingZones(time.Minute, brakeAt, 40)
+	for i := range lap.DataHistory {
+		data := &lap.DataHistory[i]
+		data.SuspensionFL, data.SuspensionFR, data.SuspensionRL, data.SuspensionRR = 0.1, 0.1, 0.1, 0.1
+		data.RideHeight = 80
+		if data.Brake > brakeThreshold {
+			data.SuspensionFL += 0.02
+			data.SuspensionFR += 0.02
+		}
+		for _, start := range brakeAt {
+			p := int32(i) - start
+			if p >= 0 && p < 200 {
+				data.SuspensionFL += 0.01
+				data.SuspensionRL += 0.01
+			}
+		}
+		if i >= bottomAt && i < bottomAt+5 {
+			data.RideHeight = 10
+		}
+	}
+	return lap
+}
+
+func Test_getHistogram(t *testing.T) {
+	h := getHistogram([]float32{0.1, 0.101, 0.107, 0.112}, 0.005)
+	assert.Equal(t, float32(0.1), h.Min)
+	assert.Equal(t, []int{2, 1, 1}, h.Counts)
+
+	assert.Equal(t, []int{}, getHistogram([]float32{}, 0.005).Counts)
+
+	// garbage readings do not allocate bins without bound
+	h = getHistogram([]float32{0.1, float32(math.NaN()), float32(math.Inf(1)), 1e30}, 0.005)
+	assert.Len(t, h.Counts, maxHistogramBins)
+	assert.Equal(t, 1, h.Counts[0])
+	assert.Equal(t, 1, h.Counts[maxHistogramBins-1])
+	assert.Equal(t, []int{}, getHistogram([]float32{float32(math.NaN())}, 0.005).Counts)
+}
+
+func TestGetBottomingEvents(t *testing.T) {
+	events := GetBottomingEvents(getLapWithSuspension([]int32{500}, 1000))
+	assert.Len(t, events, 1)
+	assert.Equal(t, float32(10), events[0].RideHeight)
+	assert.Equal(t, packageNumbersToDuration(5), events[0].Duration)
+
+	// live only every sixth package is stored
+	events = GetBottomingEvents(getLiveSamples(getLapWithSuspension([]int32{500}, 1000), 6))
+	assert.Len(t, events, 1)
+	assert.InDelta(t, packageNumbersToDuration(5).Seconds(), events[0].Duration.Seconds(), 0.1)
+
+	assert.Len(t, GetBottomingEvents(getLapWithSuspension([]int32{500}, -10)), 0)
+	assert.Len(t, GetBottomingEvents(Lap{}), 0)
+}
+
+func TestGetRideHeightBySpeed(t *testing.T) {
+	rideHeights := GetRideHeightBySpeed(getLapWithSuspension([]int32{500}, -10))
+	assert.Equal(t, float32(80), rideHeights[0].Speed)
+	assert.Equal(t, float32(180), rideHeights[len(rideHeights)-1].Speed)
+	for _, r := range rideHeights {
+		assert.Equal(t, float32(80), r.RideHeight)
+	}
+}
+
+func TestGetLoadTransfer(t *testing.T) {
+	transfers := GetLoadTransfer(getLapWithSuspension([]int32{500, 1500}, -10))
+	assert.Len(t, transfers, 2)
+	assert.Equal(t, 1, transfers[0].Corner)
+	// the front is 20mm more compressed, the left tires do not change the pitch
+	assert.InDelta(t, 20, transfers[0].Pitch, 0.01)
+	// 200 of 1000 packages until the next braking zone are with 10mm more on the left
+	assert.InDelta(t, 2, transfers[0].Roll, 0.1)
+
+	// live only every sixth package is stored
+	transfers = GetLoadTransfer(getLiveSamples(getLapWithSuspension([]int32{500, 1500}, -10), 6))
+	assert.Len(t, transfers, 2)
+	assert.InDelta(t, 20, transfers[0].Pitch, 0.01)
+	assert.InDelta(t, 2, transfers[0].Roll, 0.1)
+}
+
+func TestStats_GetSuspensionReport(t *testing.T) {
+	s := NewStats()
+	_, err := s.GetSuspensionReport()
+	assert.Error(t, err)
+
+	lap := getLapWithSuspension([]int32{500}, 1000)
+	for _, laps := range [][]Lap{{lap}, {getLiveSamples(lap, 6)}} {
+		s.Laps = laps
+		report, err := s.GetSuspensionReport()
+		assert.NoError(t, err)
+		assert.Len(t, report, 1)
+		assert.Len(t, report[0].Bottoming, 1)
+		assert.Len(t, report[0].LoadTransfer, 1)
+	}
+}
@@ -0,0 +1,105 @@
+package lib
+
+import (
+	"fmt"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"time"
+)
+
+type TemperatureWindow struct {
+	Min float32 `json:"min"`
+	Max float32 `json:"max"`
+}
+
+func NewTemperatureWindow() TemperatureWindow {
+	return TemperatureWindow{Min: 70, Max: 95}
+}
+
+func (w TemperatureWindow) Contains(temperature float32) bool {
+	return temperature >= w.Min && temperature <= w.Max
+}
+
+// TireTemperatures are the temperatures of the tires over a lap
+type TireTemperatures struct {
+	Lap        int16       `json:"lap"`
+	FrontLeft  HealthRange `json:"front_left"`
+	FrontRight HealthRange `json:"front_right"`
+	RearLeft   HealthRange `json:"rear_left"`
+	RearRight  HealthRange `json:"rear_right"`
+	// FrontRearBalance is the average front temperature minus the average rear temperature, positive if the fronts are hotter
+	FrontRearBalance float32 `json:"front_rear_balance"`
+	// LeftRightBalance is the average left temperature minus the average right temperature, positive if the left tires are hotter
+	LeftRightBalance float32 `json:"left_right_balance"`
+	// TimeInWindow is the time all four tires have been in the optimal window
+	TimeInWindow  time.Duration `json:"time_in_window"`
+	ShareInWindow float32       `json:"share_in_window"`
+}
+
+func (t TireTemperatures) String() string {
+	return fmt.Sprintf("%.0f %.0f %.0f %.0f°C, F/R %+.0f, L/R %+.0f, %.0f%% in window",
+		t.FrontLeft.Avg, t.FrontRight.Avg, t.RearLeft.Avg, t.RearRight.Avg,
+		t.FrontRearBalance, t.LeftRightBalance, t.ShareInWindow*100)
+}
+
+func isInTemperatureWindow(data gt7.GTData, window TemperatureWindow) bool {
+	return window.Contains(data.TyreTempFL) && window.Contains(data.TyreTempFR) &&
+		window.Contains(data.TyreTempRL) && window.Contains(data.TyreTempRR)
+}
+
+// GetTireTemperatures returns the temperatures of every tire over the lap and the time spent in the window
+func GetTireTemperatures(lap Lap, window TemperatureWindow) TireTemperatures {
+	fl, fr, rl, rr := []float32{}, []float32{}, []float32{}, []float32{}
+	inWindow := 0
+	var timeInWindow time.Duration
+	for i, data := range lap.DataHistory {
+		fl = append(fl, data.TyreTempFL)
+		fr = append(fr, data.TyreTempFR)
+		rl = append(rl, data.TyreTempRL)
+		rr = append(rr, data.TyreTempRR)
+		if isInTemperatureWindow(data, window) {
+			inWindow++
+			timeInWindow += getSampleDuration(lap.DataHistory, i)
+		}
+	}
+
+	t := TireTemperatures{
+		Lap:          lap.Number,
+		FrontLeft:    getHealthRange(fl),
+		FrontRight:   getHealthRange(fr),
+		RearLeft:     getHealthRange(rl),
+		RearRight:    getHealthRange(rr),
+		TimeInWindow: timeInWindow,
+	}
+	t.FrontRearBalance = (t.FrontLeft.Avg+t.FrontRight.Avg)/2 - (t.RearLeft.Avg+t.RearRight.Avg)/2
+	t.LeftRightBalance = (t.FrontLeft.Avg+t.RearLeft.Avg)/2 - (t.FrontRight.Avg+t.RearRight.Avg)/2
+	if len(lap.DataHistory) > 0 {
+		t.ShareInWindow = float32(inWindow) / float32(len(lap.DataHistory))
+	}
+	return t
+}
+
+// isOutLap is true if the car left the pits in the ongoing lap or the lap before ended in the pits
+func (s *Stats) isOutLap() bool {
+	if len(s.OngoingLap.PitStops) > 0 {
+		return true
+	}
+	return len(s.Laps) > 0 && len(s.Laps[len(s.Laps)-1].PitStops) > 0
+}
+
+// GetColdTireWarning warns on out-laps as long as a tire is below the optimal window
+func (s *Stats) GetColdTireWarning() string {
+	if !s.isOutLap() {
+		return ""
+	}
+	data := s.LastData
+	coldest := data.TyreTempFL
+	for _, temperature := range []float32{data.TyreTempFR, data.TyreTempRL, data.TyreTempRR} {
+		if temperature < coldest {
+			coldest = temperature
+		}
+	}
+	if coldest >= s.Settings.TireTempWindow.Min {
+		return ""
+	}
+	return fmt.Sprintf("cold tires on out lap: %.0f°C, window starts at %.0f°C", coldest, s.Settings.TireTempWindow.Min)
+}
@@ -0,0 +1,57 @@
+package lib
+
+import (
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetTireTemperatures(t *testing.T) {
+	lap := Lap{Number: 3}
+	for i := 0; i < 100; i++ {
+		// the tires warm up into the window after 25 packages
+		temperature := float32(60 + i)
+		lap.DataHistory = append(lap.DataHistory, gt7.GTData{
+			PackageID:  int32(i),
+			TyreTempFL: temperature + 10,
+			TyreTempFR: temperature + 6,
+			TyreTempRL: temperature + 4,
+			TyreTempRR: temperature,
+		})
+	}
+
+	temperatures := GetTireTemperatures(lap, TemperatureWindow{Min: 70, Max: 200})
+	assert.Equal(t, int16(3), temperatures.Lap)
+	assert.Equal(t, float32(70), temperatures.FrontLeft.Min)
+	assert.Equal(t, float32(169), temperatures.FrontLeft.Max)
+	assert.Equal(t, float32(109.5), temperatures.RearRight.Avg)
+	assert.Equal(t, float32(6), temperatures.FrontRearBalance)
+	assert.Equal(t, float32(4), temperatures.LeftRightBalance)
+	// the right rear tire is in the window from package 10 on
+	assert.Equal(t, packageNumbersToDuration(90), temperatures.TimeInWindow)
+	assert.Equal(t, float32(0.9), temperatures.ShareInWindow)
+	assert.Equal(t, "120 116 114 110°C, F/R +6, L/R +4, 90% in window", temperatures.String())
+
+	assert.Equal(t, TireTemperatures{}, GetTireTemperatures(Lap{}, NewTemperatureWindow()))
+
+	// live only every sixth package is stored, the time in the window stays the same
+	temperatures = GetTireTemperatures(getLiveSamples(lap, 6), TemperatureWindow{Min: 70, Max: 200})
+	assert.InDelta(t, packageNumbersToDuration(90).Seconds(), temperatures.TimeInWindow.Seconds(), 0.1)
+	assert.InDelta(t, 0.9, temperatures.ShareInWindow, 0.05)
+}
+
+func TestStats_GetColdTireWarning(t *testing.T) {
+	s := NewStats()
+	s.LastData = &gt7.GTData{TyreTempFL: 50, TyreTempFR: 60, TyreTempRL: 70, TyreTempRR: 80}
+	assert.Equal(t, "", s.GetColdTireWarning(), "no out lap")
+
+	s.OngoingLap.PitStops = []PitStop{{FuelAdded: 10}}
+	assert.Equal(t, "cold tires on out lap: 50°C, window starts at 70°C", s.GetColdTireWarning())
+
+	s.OngoingLap.PitStops = nil
+	s.Laps = []Lap{{Number: 2, PitStops: []PitStop{{FuelAdded: 10}}}}
+	assert.NotEqual(t, "", s.GetColdTireWarning(), "lap after the pit stop is an out lap")
+
+	s.LastData = &gt7.GTData{TyreTempFL: 75, TyreTempFR: 75, TyreTempRL: 75, TyreTempRR: 75}
+	assert.Equal(t, "", s.GetColdTireWarning())
+}
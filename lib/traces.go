@@ -0,0 +1,116 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	svg "github.com/ajstarks/svgo"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+)
+
+const TraceSpeed = "speed"
+const TraceThrottle = "throttle"
+const TraceBrake = "brake"
+const TraceRPM = "rpm"
+const TraceGear = "gear"
+
+var TraceChannels = []string{TraceSpeed, TraceThrottle, TraceBrake, TraceRPM, TraceGear}
+
+// traceDetail is the number of packages per point of a trace, higher is less detail
+const traceDetail = 5
+
+const traceWidth = 600
+const traceHeight = 120
+const tracePadding = 30
+
+type traceChannel struct {
+	label string
+	// max is the fixed top of the chart, 0 to scale to the maximum of the laps
+	max   float32
+	value func(data gt7.GTData) float32
+}
+
+var traceChannels = map[string]traceChannel{
+	TraceSpeed:    {label: "Speed km/h", value: func(data gt7.GTData) float32 { return data.CarSpeed }},
+	TraceThrottle: {label: "Throttle %", max: 100, value: func(data gt7.GTData) float32 { return data.Throttle }},
+	TraceBrake:    {label: "Brake %", max: 100, value: func(data gt7.GTData) float32 { return data.Brake }},
+	TraceRPM:      {label: "RPM", value: func(data gt7.GTData) float32 { return data.RPM }},
+	TraceGear:     {label: "Gear", value: func(data gt7.GTData) float32 { return float32(data.CurrentGear) }},
+}
+
+func getTracePoints(lap Lap, c traceChannel, maxDistance float32, maxValue float32) ([]int, []int) {
+	xs, ys := []int{}, []int{}
+	distances := lap.GetDistances()
+	for i := 0; i < len(lap.DataHistory); i += traceDetail {
+		xs = append(xs, tracePadding+int(distances[i]/maxDistance*traceWidth))
+		ys = append(ys, tracePadding+traceHeight-int(c.value(lap.DataHistory[i])/maxValue*traceHeight))
+	}
+	return xs, ys
+}
+
+// DrawTraceSVG charts the channel, one of TraceChannels, over the distance of the lap. Without
+// data in the reference lap no reference is drawn
+func DrawTraceSVG(lap Lap, reference Lap, channel string) (string, error) {
+	c, ok := traceChannels[channel]
+	if !ok {
+		return "", fmt.Errorf("unknown channel %q, use one of %v", channel, TraceChannels)
+	}
+	if len(lap.DataHistory) == 0 {
+		return "", fmt.Errorf("no data in lap %d", lap.Number)
+	}
+
+	laps := []Lap{lap}
+	if len(reference.DataHistory) > 0 {
+		laps = append(laps, reference)
+	}
+	maxDistance, maxValue := float32(1), c.max
+	for _, l := range laps {
+		distances := l.GetDistances()
+		if distances[len(distances)-1] > maxDistance {
+			maxDistance = distances[len(distances)-1]
+		}
+		if c.max > 0 {
+			continue
+		}
+		for _, data := range l.DataHistory {
+			if c.value(data) > maxValue {
+				maxValue = c.value(data)
+			}
+		}
+	}
+	if maxValue <= 0 {
+		maxValue = 1
+	}
+
+	buf := new(bytes.Buffer)
+	canvas := svg.New(buf)
+	canvas.Startview(traceWidth+2*tracePadding, traceHeight+2*tracePadding, 0, 0, traceWidth+2*tracePadding, traceHeight+2*tracePadding)
+	canvas.Line(tracePadding, tracePadding+traceHeight, tracePadding+traceWidth, tracePadding+traceHeight, "stroke:black")
+	canvas.Line(tracePadding, tracePadding, tracePadding, tracePadding+traceHeight, "stroke:black")
+	canvas.Text(5, tracePadding-10, fmt.Sprintf("%s, max %.0f", c.label, maxValue), "font-size:12px")
+	canvas.Text(tracePadding+traceWidth, 2*tracePadding+traceHeight-5, fmt.Sprintf("%.0f m", maxDistance), "text-anchor:end;font-size:12px")
+
+	// the reference is drawn first to be below the lap
+	if len(laps) > 1 {
+		xs, ys := getTracePoints(reference, c, maxDistance, maxValue)
+		canvas.Polyline(xs, ys, "fill:none;stroke:grey;stroke-width:1;stroke-dasharray:4", `class="reference"`)
+		canvas.Text(tracePadding+traceWidth, tracePadding-10, fmt.Sprintf("Lap %d vs. %d", lap.Number, reference.Number), "text-anchor:end;font-size:12px")
+	}
+	xs, ys := getTracePoints(lap, c, maxDistance, maxValue)
+	canvas.Polyline(xs, ys, "fill:none;stroke:blue;stroke-width:1.5")
+
+	canvas.End()
+	return buf.String(), nil
+}
+
+// DrawTracesSVG charts every channel of TraceChannels for the lap
+func DrawTracesSVG(lap Lap, reference Lap) (map[string]string, error) {
+	traces := map[string]string{}
+	for _, channel := range TraceChannels {
+		trace, err := DrawTraceSVG(lap, reference, channel)
+		if err != nil {
+			return traces, err
+		}
+		traces[channel] = trace
+	}
+	return traces, nil
+}
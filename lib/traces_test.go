@@ -0,0 +1,44 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDrawTraceSVG(t *testing.T) {
+	lap := getLapOnStraight(180)
+	reference := getLapOnStraight(200)
+	reference.Number = 3
+
+	svg, err := DrawTraceSVG(lap, Lap{}, TraceSpeed)
+	assert.NoError(t, err)
+	assert.Contains(t, svg, "<svg")
+	assert.Contains(t, svg, "polyline")
+	assert.Contains(t, svg, "Speed km/h, max 180")
+	assert.NotContains(t, svg, `class="reference"`)
+
+	svg, err = DrawTraceSVG(lap, reference, TraceSpeed)
+	assert.NoError(t, err)
+	assert.Contains(t, svg, "Speed km/h, max 200")
+	assert.Contains(t, svg, `class="reference"`)
+	assert.Contains(t, svg, "Lap 2 vs. 3")
+
+	svg, err = DrawTraceSVG(lap, reference, TraceBrake)
+	assert.NoError(t, err)
+	assert.Contains(t, svg, "Brake %, max 100")
+
+	_, err = DrawTraceSVG(lap, reference, "yaw")
+	assert.Error(t, err)
+	_, err = DrawTraceSVG(Lap{}, reference, TraceSpeed)
+	assert.Error(t, err)
+}
+
+func TestDrawTracesSVG(t *testing.T) {
+	traces, err := DrawTracesSVG(getLapOnStraight(180), Lap{})
+	assert.NoError(t, err)
+	assert.Len(t, traces, len(TraceChannels))
+	assert.Contains(t, traces[TraceRPM], "RPM")
+
+	_, err = DrawTracesSVG(Lap{}, Lap{})
+	assert.Error(t, err)
+}
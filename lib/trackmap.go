@@ -0,0 +1,152 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	svg "github.com/ajstarks/svgo"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"math"
+)
+
+// trackMapTrailLaps is the number of previous laps drawn as a faint trail
+const trackMapTrailLaps = 2
+
+// trackMapMinSize is the minimum width and height of the map in meters, the map of the first
+// positions would be too small for the car to move in
+const trackMapMinSize = 1000
+
+// trackMapRefreshSamples is the number of samples after which the map is drawn again as long as there
+// is no outline, the view of the map only changes with the heavy message
+const trackMapRefreshSamples = 50
+
+// trailDetail is the number of packages per point of a trail, higher is less detail
+const trailDetail = 10
+
+// GetHeading returns the direction the car is facing in degrees. RotationYaw is the vertical
+// component of the rotation quaternion, which is the sine of half the angle
+func GetHeading(data gt7.GTData) float32 {
+	yaw := math.Max(-1, math.Min(1, float64(data.RotationYaw)))
+	return float32(2 * math.Asin(yaw) * 180 / math.Pi)
+}
+
+// getOutlineLap returns the lap the track outline is drawn from, the best lap or else the last lap with data
+func (s *Stats) getOutlineLap() (Lap, error) {
+	bestLap, err := s.GetBestLap()
+	if err == nil {
+		return bestLap, nil
+	}
+	for i := len(s.Laps) - 1; i >= 0; i-- {
+		if len(s.Laps[i].DataHistory) > 0 {
+			return s.Laps[i], nil
+		}
+	}
+	return Lap{}, fmt.Errorf("no lap with data, nr of laps: %d", len(s.Laps))
+}
+
+func getTrailPoints(history []gt7.GTData) ([]int, []int) {
+	xs, ys := []int{}, []int{}
+	for i := 0; i < len(history); i += trailDetail {
+		xs = append(xs, int(history[i].PositionX))
+		ys = append(ys, int(history[i].PositionZ))
+	}
+	return xs, ys
+}
+
+// getTrackMapExtent returns the padded size and the start of the map along one axis, at least trackMapMinSize
+func getTrackMapExtent(min float64, max float64) (int, int) {
+	size := int(max-min) + 2*mapPadding
+	if size < trackMapMinSize {
+		size = trackMapMinSize
+	}
+	return size, int((min+max)/2) - size/2
+}
+
+// refreshTrackMap requests the heavy message every few samples as long as there is no outline,
+// so that the map grows with the part of the track driven so far
+func (s *Stats) refreshTrackMap() {
+	if _, err := s.getOutlineLap(); err == nil {
+		return
+	}
+	if len(s.OngoingLap.DataHistory)%trackMapRefreshSamples == 0 {
+		s.HeavyMessageNeedsRefresh = true
+	}
+}
+
+// DrawTrackMapSVG draws the outline of the best lap with the start finish line, the pit stops,
+// the trails of the previous laps and of the ongoing lap and the car. The client moves the
+// car-position group and extends the trail polyline with every position update
+func (s *Stats) DrawTrackMapSVG() string {
+	outline, outlineErr := s.getOutlineLap()
+
+	previousLaps := []Lap{}
+	for i := len(s.Laps) - 1; i >= 0 && len(previousLaps) < trackMapTrailLaps; i-- {
+		if len(s.Laps[i].DataHistory) > 0 {
+			previousLaps = append(previousLaps, s.Laps[i])
+		}
+	}
+
+	view := append([]gt7.GTData{}, outline.DataHistory...)
+	for _, lap := range previousLaps {
+		view = append(view, lap.DataHistory...)
+	}
+	view = append(view, s.OngoingLap.DataHistory...)
+	if s.LastData != nil && s.LastData.PackageID != 0 {
+		view = append(view, *s.LastData)
+	}
+
+	buf := new(bytes.Buffer)
+	canvas := svg.New(buf)
+	// without any position the map is centered on the origin
+	var maxx, maxz, minx, minz float64
+	if len(view) > 0 {
+		maxx, maxz, minx, minz = getBounds(view)
+	}
+	width, left := getTrackMapExtent(minx, maxx)
+	height, top := getTrackMapExtent(minz, maxz)
+	canvas.Startview(width, height, left, top, width, height)
+
+	if outlineErr == nil {
+		path := getLapPath(outline, 5)
+		canvas.Path(path, "fill:none;stroke:black;stroke-width:14", `class="outline"`)
+		canvas.Path(path, "fill:none;stroke:white;stroke-width:10")
+
+		start := outline.DataHistory[0]
+		canvas.Circle(int(start.PositionX), int(start.PositionZ), 10, "fill:white;stroke:black;stroke-width:4", `class="start-finish"`)
+	}
+
+	for _, lap := range previousLaps {
+		xs, ys := getTrailPoints(lap.DataHistory)
+		canvas.Polyline(xs, ys, "fill:none;stroke:grey;stroke-width:3;stroke-opacity:0.4", `class="previous-trail"`)
+	}
+	xs, ys := getTrailPoints(s.OngoingLap.DataHistory)
+	trailStyle := "fill:none;stroke:blue;stroke-width:4"
+	if len(xs) > 0 {
+		canvas.Polyline(xs, ys, trailStyle, `class="trail"`)
+	} else {
+		// svgo does not draw polylines without points, the client extends the trail nevertheless
+		fmt.Fprintf(canvas.Writer, "<polyline points=\"\" style=\"%s\" class=\"trail\" />\n", trailStyle)
+	}
+
+	for _, lap := range append(append([]Lap{}, s.Laps...), s.OngoingLap) {
+		for _, pitStop := range lap.PitStops {
+			canvas.Circle(int(pitStop.PositionX), int(pitStop.PositionZ), 12, "fill:blue", `class="pit"`)
+			canvas.Text(int(pitStop.PositionX), int(pitStop.PositionZ)+6, "P", "fill:white;font-size:16px;text-anchor:middle")
+		}
+	}
+
+	if len(previousLaps) > 0 {
+		drawSlipEvents(canvas, previousLaps[0])
+	}
+
+	// the car is a triangle pointing in driving direction, it is hidden until the first package arrives
+	position, visibility := CarPosition{}, "hidden"
+	if s.LastData != nil && s.LastData.PackageID != 0 {
+		position, visibility = s.GetCarPosition(), "visible"
+	}
+	canvas.Group(`class="car-position"`, fmt.Sprintf(`transform="translate(%.1f,%.1f) rotate(%.1f)"`, position.X, position.Y, position.Facing), fmt.Sprintf(`visibility="%s"`, visibility))
+	canvas.Polygon([]int{0, -12, 12}, []int{-20, 12, 12}, "fill:red;stroke:black;stroke-width:2")
+	canvas.Gend()
+
+	canvas.End()
+	return buf.String()
+}
@@ -0,0 +1,54 @@
+package lib
+
+import (
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestGetHeading(t *testing.T) {
+	assert.Equal(t, float32(0), GetHeading(gt7.GTData{RotationYaw: 0}))
+	assert.InDelta(t, 90, GetHeading(gt7.GTData{RotationYaw: float32(math.Sin(math.Pi / 4))}), 0.01)
+	assert.InDelta(t, -180, GetHeading(gt7.GTData{RotationYaw: -1}), 0.01)
+}
+
+func TestStats_DrawTrackMapSVG(t *testing.T) {
+	s := NewStats()
+	svg := s.DrawTrackMapSVG()
+	// the client needs the car and the trail to move before the first lap
+	assert.Contains(t, svg, `viewBox="-500 -500 1000 1000"`)
+	assert.Contains(t, svg, `class="trail"`)
+	assert.Contains(t, svg, `visibility="hidden"`)
+
+	lap := getLapOnStraight(180)
+	lap.PitStops = []PitStop{{FuelAdded: 50, PositionX: -100, PositionZ: -90}}
+	s.Laps = []Lap{lap}
+	s.OngoingLap.DataHistory = lap.DataHistory[:100]
+	s.LastData = &gt7.GTData{PackageID: 1100, PositionX: -100, PositionZ: -100, RotationYaw: 1}
+
+	svg = s.DrawTrackMapSVG()
+	assert.Contains(t, svg, `class="outline"`)
+	assert.Contains(t, svg, `class="start-finish"`)
+	assert.Contains(t, svg, `class="previous-trail"`)
+	assert.Contains(t, svg, `class="pit"`)
+	assert.Contains(t, svg, `transform="translate(-100.0,-100.0) rotate(180.0)" visibility="visible"`)
+	// the trail of the ongoing lap has a point every trailDetail packages
+	assert.Contains(t, svg, `<polyline points="0,-100 -10,-100 -20,-100 -30,-100 -40,-100 -50,-100 -60,-100 -70,-100 -80,-100 -90,-100"`)
+}
+
+func TestStats_refreshTrackMap(t *testing.T) {
+	s := NewStats()
+	raceTimeInMinutes := 0
+	for i := 1; i <= trackMapRefreshSamples; i++ {
+		LogTick(&gt7.GTData{PackageID: int32(i)}, s, &raceTimeInMinutes)
+	}
+	assert.True(t, s.HeavyMessageNeedsRefresh, "the map is drawn again while there is no outline")
+
+	s.HeavyMessageNeedsRefresh = false
+	s.Laps = []Lap{getLapOnStraight(180)}
+	for i := 1; i <= trackMapRefreshSamples; i++ {
+		LogTick(&gt7.GTData{PackageID: int32(1000 + i)}, s, &raceTimeInMinutes)
+	}
+	assert.False(t, s.HeavyMessageNeedsRefresh)
+}
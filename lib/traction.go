@@ -0,0 +1,221 @@
+package lib
+
+import (
+	"fmt"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"time"
+)
+
+// fullThrottle is the throttle in percent that counts as full throttle
+const fullThrottle = 98
+
+// exitDistance is the distance in meters after the brake release that belongs to the corner exit
+const exitDistance = 200
+
+// wheelspinSlip is the rear wheel slip above which the rear tires are spinning, 0.1 means the
+// rear tires turn 10% faster than the car drives
+const wheelspinSlip = 0.1
+
+// minSlipSpeed is the speed in km/h below which the slip ratio is too noisy to be used
+const minSlipSpeed = 20
+
+// CornerExit describes the exit of a corner from the brake release on
+type CornerExit struct {
+	Corner int `json:"corner"`
+	// Start is the distance from the start of the lap where the brake is released
+	Start float32 `json:"start"`
+	// TimeToFullThrottle is the time from the brake release to full throttle, -1 if full throttle is not reached on the exit
+	TimeToFullThrottle time.Duration `json:"time_to_full_throttle"`
+	TCSDuration        time.Duration `json:"tcs_duration"`
+	WheelspinDuration  time.Duration `json:"wheelspin_duration"`
+	MaxRearSlip        float32       `json:"max_rear_slip"`
+}
+
+// TractionSummary aggregates the traction of a whole lap
+type TractionSummary struct {
+	Lap               int16         `json:"lap"`
+	TCSDuration       time.Duration `json:"tcs_duration"`
+	WheelspinDuration time.Duration `json:"wheelspin_duration"`
+	// AverageRearSlip is the average rear wheel slip while on throttle
+	AverageRearSlip float32 `json:"average_rear_slip"`
+	MaxRearSlip     float32 `json:"max_rear_slip"`
+}
+
+// CornerTraction averages the exits of a corner over all regular laps
+type CornerTraction struct {
+	Corner int `json:"corner"`
+	Exits  int `json:"exits"`
+	// AverageTimeToFullThrottle only counts exits where full throttle has been reached
+	AverageTimeToFullThrottle time.Duration `json:"average_time_to_full_throttle"`
+	AverageTCSDuration        time.Duration `json:"average_tcs_duration"`
+	AverageWheelspinDuration  time.Duration `json:"average_wheelspin_duration"`
+	MaxRearSlip               float32       `json:"max_rear_slip"`
+}
+
+type TractionAnalysis struct {
+	Laps    []TractionSummary `json:"laps"`
+	Corners []CornerTraction  `json:"corners"`
+}
+
+// getTyreSlips returns by how much every tire turns faster than the car drives, negative if it turns
+// slower, in the order front left, front right, rear left, rear right. Below minSlipSpeed the slip
+// ratio is too noisy and every slip is 0
+func getTyreSlips(data gt7.GTData) [4]float32 {
+	if data.CarSpeed < minSlipSpeed {
+		return [4]float32{}
+	}
+	return [4]float32{data.TyreSlipRatioFL - 1, data.TyreSlipRatioFR - 1, data.TyreSlipRatioRL - 1, data.TyreSlipRatioRR - 1}
+}
+
+// getRearSlip returns by how much the faster rear tire turns faster than the car drives
+func getRearSlip(data gt7.GTData) float32 {
+	slips := getTyreSlips(data)
+	slip := slips[2]
+	if slips[3] > slip {
+		slip = slips[3]
+	}
+	if slip < 0 {
+		return 0
+	}
+	return slip
+}
+
+// GetCornerExits measures the exit of every braking zone of the lap until full throttle and beyond
+// for exitDistance meters or until the next braking zone
+func GetCornerExits(lap Lap) []CornerExit {
+	exits := []CornerExit{}
+	zones := GetBrakingZones(lap)
+	distances := lap.GetDistances()
+
+	for z, zone := range zones {
+		exitEnd := len(lap.DataHistory)
+		if z+1 < len(zones) {
+			exitEnd = zones[z+1].startIndex
+		}
+
+		release := lap.DataHistory[zone.endIndex]
+		exit := CornerExit{Corner: zone.Corner, Start: zone.End, TimeToFullThrottle: -1}
+		fullThrottleReached := false
+
+		for i := zone.endIndex + 1; i < exitEnd; i++ {
+			data := lap.DataHistory[i]
+
+			if !fullThrottleReached && data.Throttle >= fullThrottle {
+				exit.TimeToFullThrottle = packageNumbersToDuration(data.PackageID - release.PackageID)
+				fullThrottleReached = true
+			}
+			if fullThrottleReached && distances[i]-zone.End > exitDistance {
+				break
+			}
+
+			if data.IsTCSEngaged {
+				exit.TCSDuration += getSampleDuration(lap.DataHistory, i)
+			}
+			slip := getRearSlip(data)
+			if slip > wheelspinSlip {
+				exit.WheelspinDuration += getSampleDuration(lap.DataHistory, i)
+			}
+			if slip > exit.MaxRearSlip {
+				exit.MaxRearSlip = slip
+			}
+		}
+		exits = append(exits, exit)
+	}
+	return exits
+}
+
+// GetTractionSummary sums up TCS interventions and wheel spin over the whole lap
+func GetTractionSummary(lap Lap) TractionSummary {
+	summary := TractionSummary{Lap: lap.Number}
+	var totalSlip float32
+	n := 0
+	for i, data := range lap.DataHistory {
+		if data.IsTCSEngaged {
+			summary.TCSDuration += getSampleDuration(lap.DataHistory, i)
+		}
+		slip := getRearSlip(data)
+		if slip > wheelspinSlip {
+			summary.WheelspinDuration += getSampleDuration(lap.DataHistory, i)
+		}
+		if slip > summary.MaxRearSlip {
+			summary.MaxRearSlip = slip
+		}
+		if data.Throttle > 0 && data.CarSpeed >= minSlipSpeed {
+			totalSlip += slip
+			n++
+		}
+	}
+	if n > 0 {
+		summary.AverageRearSlip = totalSlip / float32(n)
+	}
+	return summary
+}
+
+// getCornerTraction averages the corner exits of the laps, corners are numbered like in the best lap
+func getCornerTraction(laps []Lap, bestLap Lap) []CornerTraction {
+	bestZones := GetBrakingZones(bestLap)
+	corners := make([]CornerTraction, len(bestZones))
+	fullThrottleExits := make([]int, len(bestZones))
+	for i, zone := range bestZones {
+		corners[i].Corner = zone.Corner
+	}
+
+	for _, lap := range laps {
+		if !lap.IsRegularLap() {
+			continue
+		}
+		zones := GetBrakingZones(lap)
+		for _, exit := range GetCornerExits(lap) {
+			best, err := matchBrakingZone(zones[exit.Corner-1], bestZones)
+			if err != nil {
+				continue
+			}
+			corner := &corners[best.Corner-1]
+			corner.Exits++
+			corner.AverageTCSDuration += exit.TCSDuration
+			corner.AverageWheelspinDuration += exit.WheelspinDuration
+			if exit.TimeToFullThrottle >= 0 {
+				corner.AverageTimeToFullThrottle += exit.TimeToFullThrottle
+				fullThrottleExits[best.Corner-1]++
+			}
+			if exit.MaxRearSlip > corner.MaxRearSlip {
+				corner.MaxRearSlip = exit.MaxRearSlip
+			}
+		}
+	}
+
+	for i := range corners {
+		if corners[i].Exits > 0 {
+			corners[i].AverageTCSDuration /= time.Duration(corners[i].Exits)
+			corners[i].AverageWheelspinDuration /= time.Duration(corners[i].Exits)
+		}
+		if fullThrottleExits[i] > 0 {
+			corners[i].AverageTimeToFullThrottle /= time.Duration(fullThrottleExits[i])
+		} else {
+			corners[i].AverageTimeToFullThrottle = -1
+		}
+	}
+	return corners
+}
+
+// GetTractionAnalysis returns the traction of every lap and of every corner averaged over the regular laps
+func (s *Stats) GetTractionAnalysis() (TractionAnalysis, error) {
+	analysis := TractionAnalysis{Laps: []TractionSummary{}, Corners: []CornerTraction{}}
+	if len(s.Laps) == 0 {
+		return analysis, fmt.Errorf("no lap driven yet")
+	}
+	for _, lap := range s.Laps {
+		analysis.Laps = append(analysis.Laps, GetTractionSummary(lap))
+	}
+
+	bestLap, err := s.GetBestLap()
+	if err != nil {
+		return analysis, fmt.Errorf("error getting best lap: %v", err)
+	}
+	analysis.Corners = getCornerTraction(s.Laps, bestLap)
+	return analysis, nil
+}
+
+func (t TractionSummary) String() string {
+	return fmt.Sprintf("TCS %.1fs, spin %.1fs", t.TCSDuration.Seconds(), t.WheelspinDuration.Seconds())
+}
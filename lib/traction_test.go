@@ -0,0 +1,134 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// getLapWithCornerExits brakes at the given packages like getLapWithBrakingZones. After the release the
+// throttle is ramped up within 50 packages and the rear tires spin with TCS for spinPackages
+func getLapWithCornerExits(duration time.Duration, brakeAt []int32, spinPackages int32) Lap {
+	lap := getLapWithBrakingZones(duration, brakeAt, 40)
+	for i := range lap.DataHistory {
+		data := &lap.DataHistory[i]
+		data.TyreSlipRatioRL = 1
+		data.TyreSlipRatioRR = 1
+		if data.Brake == 0 {
+			data.Throttle = 100
+		}
+		for _, start := range brakeAt {
+			p := int32(i) - start
+			if p < 0 || p >= 150 {
+				continue
+			}
+			if p >= 60 && p < 100 {
+				data.Throttle = 0
+			}
+			if p >= 100 {
+				data.Throttle = float32(p-99) * 2
+			}
+			if p >= 100 && p < 100+spinPackages {
+				data.TyreSlipRatioRR = 1.2
+				data.IsTCSEngaged = true
+			}
+		}
+	}
+	return lap
+}
+
+func TestGetCornerExits(t *testing.T) {
+	exits := GetCornerExits(getLapWithCornerExits(time.Minute, []int32{500, 1500}, 25))
+	assert.Len(t, exits, 2)
+
+	assert.Equal(t, 1, exits[0].Corner)
+	// the brake is released at package 96 and full throttle is reached at package 148
+	assert.Equal(t, packageNumbersToDuration(52), exits[0].TimeToFullThrottle)
+	assert.Equal(t, packageNumbersToDuration(25), exits[0].TCSDuration)
+	assert.Equal(t, packageNumbersToDuration(25), exits[0].WheelspinDuration)
+	assert.InDelta(t, 0.2, exits[0].MaxRearSlip, 0.001)
+
+	// live only every sixth package is stored, the durations stay the same
+	exits = GetCornerExits(getLiveSamples(getLapWithCornerExits(time.Minute, []int32{500, 1500}, 25), 6))
+	assert.Len(t, exits, 2)
+	assert.InDelta(t, packageNumbersToDuration(52).Seconds(), exits[0].TimeToFullThrottle.Seconds(), 0.1)
+	assert.InDelta(t, packageNumbersToDuration(25).Seconds(), exits[0].WheelspinDuration.Seconds(), 0.1)
+
+	assert.Len(t, GetCornerExits(Lap{}), 0)
+}
+
+func TestGetCornerExits_NoFullThrottle(t *testing.T) {
+	lap := getLapWithBrakingZones(time.Minute, []int32{500}, 40)
+	exits := GetCornerExits(lap)
+	assert.Len(t, exits, 1)
+	assert.Equal(t, time.Duration(-1), exits[0].TimeToFullThrottle)
+}
+
+func TestGetTractionSummary(t *testing.T) {
+	lap := getLapWithCornerExits(time.Minute, []int32{500, 1500}, 25)
+	summary := GetTractionSummary(lap)
+	assert.Equal(t, int16(2), summary.Lap)
+	assert.Equal(t, packageNumbersToDuration(50), summary.TCSDuration)
+	assert.Equal(t, packageNumbersToDuration(50), summary.WheelspinDuration)
+	assert.InDelta(t, 0.2, summary.MaxRearSlip, 0.001)
+	assert.Greater(t, summary.AverageRearSlip, float32(0))
+	assert.Equal(t, "TCS 0.8s, spin 0.8s", summary.String())
+
+	// live only every sixth package is stored, the durations stay the same
+	summary = GetTractionSummary(getLiveSamples(lap, 6))
+	assert.InDelta(t, packageNumbersToDuration(50).Seconds(), summary.TCSDuration.Seconds(), 0.1)
+	assert.InDelta(t, packageNumbersToDuration(50).Seconds(), summary.WheelspinDuration.Seconds(), 0.1)
+
+	exits := GetCornerExits(getLiveSamples(lap, 6))
+	assert.Len(t, exits, 2)
+	assert.InDelta(t, packageNumbersToDuration(25).Seconds(), exits[0].TCSDuration.Seconds(), 0.1)
+}
+
+func Test_getRearSlip(t *testing.T) {
+	lap := getLapWithCornerExits(time.Minute, []int32{500}, 25)
+	data := lap.DataHistory[600]
+	assert.InDelta(t, 0.2, getRearSlip(data), 0.001)
+
+	data.CarSpeed = 10
+	assert.Equal(t, float32(0), getRearSlip(data), "too slow to measure slip")
+
+	data.CarSpeed = 100
+	data.TyreSlipRatioRR = 0.9
+	assert.Equal(t, float32(0), getRearSlip(data), "locking tires are no wheelspin")
+}
+
+func TestStats_GetTractionAnalysis(t *testing.T) {
+	s := NewStats()
+	_, err := s.GetTractionAnalysis()
+	assert.Error(t, err)
+
+	s.Laps = []Lap{
+		getLapWithCornerExits(time.Minute, []int32{500, 1500}, 0),
+		getLapWithCornerExits(time.Minute+time.Second, []int32{500, 1500}, 50),
+	}
+	s.Laps[1].Number = 3
+
+	analysis, err := s.GetTractionAnalysis()
+	assert.NoError(t, err)
+	assert.Len(t, analysis.Laps, 2)
+	assert.Len(t, analysis.Corners, 2)
+
+	corner := analysis.Corners[0]
+	assert.Equal(t, 1, corner.Corner)
+	assert.Equal(t, 2, corner.Exits)
+	assert.Equal(t, packageNumbersToDuration(52), corner.AverageTimeToFullThrottle)
+	assert.Equal(t, packageNumbersToDuration(25), corner.AverageWheelspinDuration)
+	assert.Equal(t, packageNumbersToDuration(25), corner.AverageTCSDuration)
+
+	// live only every sixth package is stored
+	for i := range s.Laps {
+		s.Laps[i] = getLiveSamples(s.Laps[i], 6)
+	}
+	analysis, err = s.GetTractionAnalysis()
+	assert.NoError(t, err)
+	corner = analysis.Corners[0]
+	assert.Equal(t, 2, corner.Exits)
+	assert.InDelta(t, packageNumbersToDuration(52).Seconds(), corner.AverageTimeToFullThrottle.Seconds(), 0.1)
+	assert.InDelta(t, packageNumbersToDuration(25).Seconds(), corner.AverageWheelspinDuration.Seconds(), 0.1)
+	assert.InDelta(t, packageNumbersToDuration(25).Seconds(), corner.AverageTCSDuration.Seconds(), 0.1)
+}
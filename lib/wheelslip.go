@@ -0,0 +1,174 @@
+package lib
+
+import (
+	"fmt"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"log"
+	"time"
+)
+
+const SlipLockUp = "lockup"
+const SlipWheelspin = "wheelspin"
+
+// lockUpSlip is the share a wheel turns slower than the car drives to count as locked up
+const lockUpSlip = 0.2
+
+// slipAlertDuration is how long a slip event is flashed on the dashboard
+const slipAlertDuration = time.Second
+
+type SlipEvent struct {
+	Type string `json:"type"`
+	// Corner is the last braking zone before the event, 0 if it happened before the first braking zone
+	Corner int `json:"corner"`
+	// Start is the distance from the start of the lap in meters
+	Start     float32       `json:"start"`
+	Duration  time.Duration `json:"duration"`
+	PositionX float32       `json:"position_x"`
+	PositionZ float32       `json:"position_z"`
+}
+
+type CornerSlipCount struct {
+	Corner     int `json:"corner"`
+	LockUps    int `json:"lock_ups"`
+	Wheelspins int `json:"wheelspins"`
+}
+
+// slipDetector remembers the slip of the last package to flash new slip events
+type slipDetector struct {
+	current    string
+	alert      string
+	alertSince time.Time
+}
+
+// getWheelSlip compares the surface speed of the wheels to the speed of the car and returns
+// SlipLockUp, SlipWheelspin or an empty string. Every wheel can lock up, wheelspin is only detected
+// on the rear wheels as the front wheels of rear driven cars turn faster in corners and over bumps.
+// Wheelspin is measured like the rear slip of the traction analysis
+func getWheelSlip(data gt7.GTData) string {
+	for _, slip := range getTyreSlips(data) {
+		if slip < -lockUpSlip && data.Brake > brakeThreshold {
+			// a lock-up is worse than wheelspin, so it wins
+			return SlipLockUp
+		}
+	}
+	if getRearSlip(data) > wheelspinSlip && data.Throttle > 0 {
+		return SlipWheelspin
+	}
+	return ""
+}
+
+// GetSlipEvents returns the lock-ups and wheelspins of the lap, consecutive packages with the same slip form one event
+func GetSlipEvents(lap Lap) []SlipEvent {
+	events := []SlipEvent{}
+	distances := lap.GetDistances()
+	zones := GetBrakingZones(lap)
+
+	corner := 0
+	var event *SlipEvent
+	for i, data := range lap.DataHistory {
+		for corner < len(zones) && zones[corner].startIndex <= i {
+			corner++
+		}
+
+		slip := getWheelSlip(data)
+		if event != nil && event.Type == slip {
+			event.Duration += getSampleDuration(lap.DataHistory, i)
+			continue
+		}
+		if event != nil {
+			events = append(events, *event)
+			event = nil
+		}
+		if slip != "" {
+			event = &SlipEvent{
+				Type:      slip,
+				Corner:    corner,
+				Start:     distances[i],
+				Duration:  getSampleDuration(lap.DataHistory, i),
+				PositionX: data.PositionX,
+				PositionZ: data.PositionZ,
+			}
+		}
+	}
+	if event != nil {
+		events = append(events, *event)
+	}
+	return events
+}
+
+func countSlipEvents(events []SlipEvent) (int, int) {
+	lockUps, wheelspins := 0, 0
+	for _, event := range events {
+		switch event.Type {
+		case SlipLockUp:
+			lockUps++
+		case SlipWheelspin:
+			wheelspins++
+		}
+	}
+	return lockUps, wheelspins
+}
+
+func formatSlipEvents(events []SlipEvent) string {
+	lockUps, wheelspins := countSlipEvents(events)
+	return fmt.Sprintf("%d / %d", lockUps, wheelspins)
+}
+
+// GetSlipEventsPerCorner counts the slip events of all laps per corner, corners are numbered like in the best lap
+func (s *Stats) GetSlipEventsPerCorner() ([]CornerSlipCount, error) {
+	bestLap, err := s.GetBestLap()
+	if err != nil {
+		return []CornerSlipCount{}, fmt.Errorf("error getting best lap: %v", err)
+	}
+	bestZones := GetBrakingZones(bestLap)
+
+	// corner 0 collects the events before the first braking zone
+	counts := make([]CornerSlipCount, len(bestZones)+1)
+	for i := range counts {
+		counts[i].Corner = i
+	}
+
+	for _, lap := range s.Laps {
+		zones := GetBrakingZones(lap)
+		for _, event := range GetSlipEvents(lap) {
+			corner := 0
+			if event.Corner > 0 {
+				best, err := matchBrakingZone(zones[event.Corner-1], bestZones)
+				if err != nil {
+					continue
+				}
+				corner = best.Corner
+			}
+			switch event.Type {
+			case SlipLockUp:
+				counts[corner].LockUps++
+			case SlipWheelspin:
+				counts[corner].Wheelspins++
+			}
+		}
+	}
+	return counts, nil
+}
+
+// detectWheelSlip is called for every package and raises an alert when a new slip event starts
+func (s *Stats) detectWheelSlip(ld *gt7.GTData) {
+	d := &s.slipDetector
+	slip := getWheelSlip(*ld)
+	if slip != "" && slip != d.current {
+		d.alert = slip
+		d.alertSince = s.clock.Now()
+		if slip == SlipLockUp {
+			log.Printf("LOCK-UP 🛑 at lap %d\n", ld.CurrentLap)
+		}
+	}
+	d.current = slip
+}
+
+// GetSlipAlert returns the type of the last slip event as long as it should be flashed, otherwise an empty string
+func (s *Stats) GetSlipAlert() string {
+	d := s.slipDetector
+	if d.alert == "" || s.clock.Now().Sub(d.alertSince) > slipAlertDuration {
+		return ""
+	}
+	return d.alert
+}
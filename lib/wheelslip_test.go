@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"github.com/jmhodges/clock"
+	gt7 "github.com/snipem/go-gt7-telemetry/lib"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// getDataWithTyreSpeeds sets the slip ratios from the tire speeds like the telemetry does
+func getDataWithTyreSpeeds(carSpeed float32, front float32, rear float32) gt7.GTData {
+	return gt7.GTData{
+		CarSpeed:        carSpeed,
+		TypeSpeedFL:     front,
+		TypeSpeedFR:     front,
+		TypeSpeedRL:     rear,
+		TyreSpeedRR:     rear,
+		TyreSlipRatioFL: front / carSpeed,
+		TyreSlipRatioFR: front / carSpeed,
+		TyreSlipRatioRL: rear / carSpeed,
+		TyreSlipRatioRR: rear / carSpeed,
+	}
+}
+
+func Test_getWheelSlip(t *testing.T) {
+	data := getDataWithTyreSpeeds(100, 100, 100)
+	assert.Equal(t, "", getWheelSlip(data))
+
+	data = getDataWithTyreSpeeds(100, 50, 100)
+	data.Brake = 80
+	assert.Equal(t, SlipLockUp, getWheelSlip(data))
+
+	data.Brake = 0
+	assert.Equal(t, "", getWheelSlip(data), "slow wheels without braking are no lock-up")
+
+	data = getDataWithTyreSpeeds(100, 100, 130)
+	data.Throttle = 100
+	assert.Equal(t, SlipWheelspin, getWheelSlip(data))
+
+	data = getDataWithTyreSpeeds(100, 130, 100)
+	data.Throttle = 100
+	assert.Equal(t, "", getWheelSlip(data), "fast front wheels of a rear driven car are no wheelspin")
+
+	data = getDataWithTyreSpeeds(10, 0, 0)
+	data.Brake = 100
+	assert.Equal(t, "", getWheelSlip(data), "too slow to detect slip")
+
+	// the wheelspin of the slip events is the rear slip of the traction analysis
+	data = getDataWithTyreSpeeds(100, 100, 105)
+	data.TyreSlipRatioRR = 1.2
+	data.Throttle = 100
+	assert.Equal(t, SlipWheelspin, getWheelSlip(data))
+	assert.InDelta(t, 0.2, getRearSlip(data), 0.001)
+}
+
+func TestGetSlipEvents(t *testing.T) {
+	lap := getLapWithBrakingZones(time.Minute, []int32{500, 1500}, 40)
+	for i := range lap.DataHistory {
+		data := &lap.DataHistory[i]
+		data.TyreSlipRatioFL, data.TyreSlipRatioFR, data.TyreSlipRatioRL, data.TyreSlipRatioRR = 1, 1, 1, 1
+		switch {
+		case i >= 520 && i < 530:
+			// locked front wheels in the first braking zone
+			data.TyreSlipRatioFL = 0
+		case i >= 1700 && i < 1705:
+			data.Throttle = 100
+			data.TyreSlipRatioRR = 1.5
+		}
+	}
+
+	events := GetSlipEvents(lap)
+	assert.Len(t, events, 2)
+
+	assert.Equal(t, SlipLockUp, events[0].Type)
+	assert.Equal(t, 1, events[0].Corner)
+	assert.Equal(t, packageNumbersToDuration(10), events[0].Duration)
+
+	assert.Equal(t, SlipWheelspin, events[1].Type)
+	assert.Equal(t, 2, events[1].Corner)
+	assert.Equal(t, packageNumbersToDuration(5), events[1].Duration)
+
+	assert.Equal(t, "1 / 1", formatSlipEvents(events))
+
+	// live only every sixth package is stored, the durations stay the same
+	liveEvents := GetSlipEvents(getLiveSamples(lap, 6))
+	assert.Len(t, liveEvents, 2)
+	assert.InDelta(t, packageNumbersToDuration(10).Seconds(), liveEvents[0].Duration.Seconds(), 0.1)
+	assert.InDelta(t, packageNumbersToDuration(5).Seconds(), liveEvents[1].Duration.Seconds(), 0.1)
+
+	s := NewStats()
+	for _, laps := range [][]Lap{{lap}, {getLiveSamples(lap, 6)}} {
+		s.Laps = laps
+		counts, err := s.GetSlipEventsPerCorner()
+		assert.NoError(t, err)
+		assert.Equal(t, []CornerSlipCount{
+			{Corner: 0},
+			{Corner: 1, LockUps: 1},
+			{Corner: 2, Wheelspins: 1},
+		}, counts)
+	}
+
+	svg := DrawLapToSVG(lap)
+	assert.Contains(t, svg, "fill:red")
+	assert.Contains(t, svg, "fill:orange")
+}
+
+func TestStats_GetSlipAlert(t *testing.T) {
+	s := NewStats()
+	fakeClock := clock.NewFake()
+	s.setClock(fakeClock)
+	assert.Equal(t, "", s.GetSlipAlert())
+
+	data := getDataWithTyreSpeeds(100, 50, 100)
+	data.Brake = 80
+	s.detectWheelSlip(&data)
+	assert.Equal(t, SlipLockUp, s.GetSlipAlert())
+
+	fakeClock.Add(slipAlertDuration / 2)
+	data = getDataWithTyreSpeeds(100, 100, 100)
+	s.detectWheelSlip(&data)
+	assert.Equal(t, SlipLockUp, s.GetSlipAlert(), "alert is still flashing")
+
+	fakeClock.Add(slipAlertDuration)
+	assert.Equal(t, "", s.GetSlipAlert())
+}